@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/n8sPxD/mcp-server-demo/config"
+	"github.com/n8sPxD/mcp-server-demo/logging"
+	"github.com/n8sPxD/mcp-server-demo/proxy"
+	"github.com/n8sPxD/mcp-server-demo/server"
+	"github.com/n8sPxD/mcp-server-demo/tools"
+)
+
+// app 是按配置组装好的服务器，以及它所依赖的插件、下游服务器和日志文件
+type app struct {
+	mu       sync.Mutex     // 串行化配置的应用和关闭
+	cfg      *config.Config // 当前已应用的配置
+	logFile  *os.File
+	logger   *slog.Logger
+	logLevel *slog.LevelVar // 日志级别，重新加载配置时可以修改
+	server   *server.MCPServer
+	plugins  map[string]loadedPlugin  // 按插件名索引的已加载插件
+	mounts   map[string]mountedServer // 按服务器名索引的已挂载下游服务器
+}
+
+// mountedServer 是一个已挂载的下游服务器及挂载时使用的配置
+type mountedServer struct {
+	config proxy.ServerConfig
+	mount  *proxy.Mount
+}
+
+// loadedPlugin 是一个已注册的插件，stamp 用于判断插件可执行文件是否被更新
+type loadedPlugin struct {
+	config tools.PluginConfig
+	stamp  string
+}
+
+// resolveConfigPath 返回要使用的配置文件路径。path 为空时使用 MCP_SERVER_CONFIG 环境变量，都未设置时返回空字符串
+func resolveConfigPath(path string) string {
+	if path == "" {
+		path = os.Getenv("MCP_SERVER_CONFIG")
+	}
+	return path
+}
+
+// loadConfig 读取配置文件，未指定配置文件时使用默认配置，见 resolveConfigPath
+func loadConfig(path string) (*config.Config, error) {
+	path = resolveConfigPath(path)
+	if path == "" {
+		return config.Default(), nil
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// newApp 打开日志文件，创建服务器，并加载插件、挂载下游服务器、应用工具设置
+func newApp(cfg *config.Config, stdin io.Reader, stdout io.Writer) (*app, error) {
+	// 日志写入文件
+	file, err := os.OpenFile(cfg.Logging.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	level := new(slog.LevelVar)
+	if err := cfg.ApplyLogLevel(level); err != nil {
+		file.Close()
+		return nil, err
+	}
+	// 日志写入文件，同时转发给通过 logging/setLevel 订阅了日志的客户端
+	forwarder := server.NewLogForwarder()
+	opts := cfg.LoggingOptions(level)
+	opts.Extra = append(opts.Extra, forwarder)
+	logger := logging.New(file, opts)
+
+	a := &app{
+		logFile:  file,
+		logger:   logger.With("component", "main"),
+		logLevel: level,
+		server:   server.NewMCPServer(stdin, stdout, logger),
+		plugins:  make(map[string]loadedPlugin),
+		mounts:   make(map[string]mountedServer),
+	}
+	a.server.ForwardLogs(forwarder)
+	a.apply(cfg)
+	return a, nil
+}
+
+// Close 关闭下游服务器和日志文件
+func (a *app) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, mounted := range a.mounts {
+		mounted.mount.Close()
+	}
+	a.logFile.Close()
+}
+
+// apply 应用配置，只改动与当前配置相比发生变化的部分。
+// 所有变化在注册表的一次批量修改中完成，会话最多收到一次 list_changed 通知
+func (a *app) apply(cfg *config.Config) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	prev := a.cfg
+	if prev == nil {
+		prev = &config.Config{}
+	}
+
+	a.server.SetServerInfo(cfg.Server.Name, cfg.Server.Version)
+	a.server.SetPageSize(cfg.Server.PageSize)
+	a.server.SetDefaultTrace(server.TraceValue(cfg.Server.Trace))
+
+	if a.cfg == nil || !reflect.DeepEqual(cfg.Weather, prev.Weather) {
+		if err := cfg.ApplyWeather(); err != nil {
+			a.logger.Error("Failed to configure weather provider", "error", err)
+		}
+	}
+
+	registry := a.server.Registry()
+	registry.Batch(func() {
+		var refreshed []string
+		// 加载外部工具插件
+		refreshed = append(refreshed, a.syncPlugins(a.pluginConfigs(cfg))...)
+		// 挂载下游 MCP 服务器
+		refreshed = append(refreshed, a.syncMounts(cfg.Downstream)...)
+
+		// 工具设置需要在所有工具注册完成之后应用
+		for _, err := range cfg.ApplyTools(registry, a.cfg, refreshed) {
+			a.logger.Warn("Failed to apply tool settings", "error", err)
+		}
+	})
+	a.cfg = cfg
+	a.reportCollisions()
+}
+
+// pluginConfigs 返回配置中声明的插件以及插件目录中发现的插件，声明的插件优先
+func (a *app) pluginConfigs(cfg *config.Config) []tools.PluginConfig {
+	configs := append([]tools.PluginConfig(nil), cfg.Plugins...)
+	if cfg.PluginDir == "" {
+		return configs
+	}
+
+	discovered, err := tools.DiscoverPlugins(cfg.PluginDir)
+	if err != nil {
+		a.logger.Error("Failed to discover plugins", "dir", cfg.PluginDir, "error", err)
+		return configs
+	}
+	declared := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		declared[config.Name] = true
+	}
+	for _, config := range discovered {
+		if declared[config.Name] {
+			a.logger.Warn("Plugin is shadowed by a plugin declared in the config", "plugin", config.Name, "dir", cfg.PluginDir)
+			continue
+		}
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+// syncPlugins 使已注册的插件与 configs 一致：移除不再需要的插件，注册新增的插件，
+// 重新注册配置或可执行文件发生变化的插件。单个插件失败不影响其他插件，返回重新注册过的工具名
+func (a *app) syncPlugins(configs []tools.PluginConfig) []string {
+	registry := a.server.Registry()
+	wanted := make(map[string]bool, len(configs))
+	var refreshed []string
+
+	for _, config := range configs {
+		wanted[config.Name] = true
+		stamp := fileStamp(config.Command)
+		old, loaded := a.plugins[config.Name]
+		if loaded && reflect.DeepEqual(old.config, config) && old.stamp == stamp {
+			continue
+		}
+
+		plugin := tools.NewPlugin(config, a.logger.With("component", "plugin"))
+		names, err := plugin.Register(context.Background(), registry)
+		if err != nil {
+			a.logger.Error("Failed to load plugin", "plugin", config.Name, "error", err)
+			continue
+		}
+		if oldSource := tools.NewPlugin(old.config, nil).Source(); loaded && oldSource != plugin.Source() {
+			registry.UnregisterSource(oldSource)
+		}
+		a.plugins[config.Name] = loadedPlugin{config: config, stamp: stamp}
+		a.logger.Info("Loaded plugin", "plugin", config.Name, "tools", names)
+		refreshed = append(refreshed, names...)
+	}
+
+	for _, name := range sortedNames(a.plugins) {
+		if wanted[name] {
+			continue
+		}
+		registry.UnregisterSource(tools.NewPlugin(a.plugins[name].config, nil).Source())
+		delete(a.plugins, name)
+		a.logger.Info("Unloaded plugin", "plugin", name)
+	}
+	return refreshed
+}
+
+// syncMounts 使已挂载的下游服务器与 configs 一致：关闭不再需要或配置发生变化的服务器，
+// 并挂载新的服务器。单个服务器失败不影响其他服务器，返回新挂载的工具名
+func (a *app) syncMounts(configs []proxy.ServerConfig) []string {
+	wanted := make(map[string]proxy.ServerConfig, len(configs))
+	for _, config := range configs {
+		wanted[config.Name] = config
+	}
+	for _, name := range sortedNames(a.mounts) {
+		if config, ok := wanted[name]; ok && reflect.DeepEqual(a.mounts[name].config, config) {
+			continue
+		}
+		a.mounts[name].mount.Close()
+		delete(a.mounts, name)
+		a.logger.Info("Unmounted downstream server", "downstream", name)
+	}
+
+	var refreshed []string
+	for _, config := range configs {
+		if _, ok := a.mounts[config.Name]; ok {
+			continue
+		}
+		mount, err := proxy.MountServer(context.Background(), a.server.Registry(), config, a.logger.With("component", "proxy"))
+		if err != nil {
+			a.logger.Error("Failed to mount downstream server", "downstream", config.Name, "error", err)
+			continue
+		}
+		a.logger.Info("Mounted downstream server", "downstream", config.Name, "tools", mount.Tools())
+		a.mounts[config.Name] = mountedServer{config: config, mount: mount}
+		refreshed = append(refreshed, mount.Tools()...)
+	}
+	return refreshed
+}
+
+// reportCollisions 记录工具重名及其解析结果
+func (a *app) reportCollisions() {
+	for _, collision := range a.server.Registry().Collisions() {
+		a.logger.Warn("Tool name collision", "collision", collision.String())
+	}
+	for _, alias := range a.server.Registry().UnresolvedAliases() {
+		a.logger.Warn("Tool alias target not found", "alias", alias)
+	}
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,268 @@
+// Package audit 记录每次工具调用的审计日志。审计日志与调试日志分开，
+// 以 JSONL 格式只追加写入，按大小或日期轮转，并可以按条件查询
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/logging"
+	"github.com/pkg/errors"
+)
+
+// 参数的记录方式
+const (
+	ArgumentsHash     = "hash"     // 只记录参数的 SHA-256 摘要
+	ArgumentsRedacted = "redacted" // 在摘要之外记录脱敏后的参数
+)
+
+// Client 是发起调用的客户端，来自 initialize 请求中的 clientInfo
+type Client struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Entry 是审计日志中的一条记录，对应一次 tools/call
+type Entry struct {
+	Time            time.Time      `json:"time"`
+	Session         string         `json:"session"` // 会话 ID 的摘要，与 /debug/status 中的 id 一致
+	Client          *Client        `json:"client,omitempty"`
+	ProtocolVersion string         `json:"protocolVersion,omitempty"`
+	RequestID       string         `json:"requestId,omitempty"`
+	Tool            string         `json:"tool"`
+	ArgumentsHash   string         `json:"argumentsHash"`
+	Arguments       map[string]any `json:"arguments,omitempty"` // 只在 ArgumentsRedacted 模式下记录
+	Outcome         string         `json:"outcome"`
+	Error           string         `json:"error,omitempty"`
+	DurationMs      float64        `json:"durationMs"`
+}
+
+// HashArguments 返回参数的 SHA-256 摘要。encoding/json 对 map 的键排序，等价的参数得到相同的摘要
+func HashArguments(arguments map[string]any) string {
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+	canonical, err := json.Marshal(arguments)
+	if err != nil {
+		canonical = []byte(fmt.Sprint(arguments))
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Options 配置审计日志的文件和轮转
+type Options struct {
+	Path       string
+	MaxSize    int64             // 当前文件超过该字节数时轮转，0 表示不按大小轮转
+	Daily      bool              // 日期 (UTC) 变化时轮转
+	MaxBackups int               // 保留的已轮转文件数，0 表示全部保留
+	Arguments  string            // ArgumentsHash 或 ArgumentsRedacted，为空时使用 ArgumentsHash
+	Redactor   *logging.Redactor // 用于参数和错误信息，为 nil 时使用默认的脱敏规则
+}
+
+// Logger 把审计记录追加写入文件
+type Logger struct {
+	opts Options
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	day  string // 当前文件对应的日期，用于按日期轮转
+}
+
+// Open 以追加方式打开审计日志文件，需要时创建所在目录
+func Open(opts Options) (*Logger, error) {
+	if opts.Arguments == "" {
+		opts.Arguments = ArgumentsHash
+	}
+	if opts.Redactor == nil {
+		opts.Redactor = logging.NewRedactor(nil, nil)
+	}
+	if dir := filepath.Dir(opts.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.Wrap(err, "failed to create audit log directory")
+		}
+	}
+
+	l := &Logger{opts: opts}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open 打开当前文件。文件已有内容时以其修改时间作为所属日期
+func (l *Logger) open() error {
+	// 审计日志中有调用方和参数信息，只允许所有者读写
+	file, err := os.OpenFile(l.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open audit log")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to stat audit log")
+	}
+
+	l.file = file
+	l.size = info.Size()
+	l.day = dayOf(time.Now())
+	if l.size > 0 {
+		l.day = dayOf(info.ModTime())
+	}
+	return nil
+}
+
+func dayOf(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// Record 补全参数摘要 (以及脱敏后的参数) 后写入一条记录。
+// 每条记录写入后都会同步到磁盘，进程崩溃也不会丢失已完成的调用
+func (l *Logger) Record(entry Entry, arguments map[string]any) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	entry.ArgumentsHash = HashArguments(arguments)
+	if l.opts.Arguments == ArgumentsRedacted && arguments != nil {
+		if redacted, ok := l.opts.Redactor.Value(arguments).(map[string]any); ok {
+			entry.Arguments = redacted
+		}
+	}
+	entry.Error = l.opts.Redactor.String(entry.Error)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit entry")
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return errors.New("audit log is closed")
+	}
+	if l.shouldRotate(entry.Time, int64(len(line))) {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	// 空文件属于它的第一条记录所在的日期
+	if l.size == 0 {
+		l.day = dayOf(entry.Time)
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return errors.Wrap(err, "failed to write audit entry")
+	}
+	return errors.Wrap(l.file.Sync(), "failed to sync audit log")
+}
+
+func (l *Logger) shouldRotate(now time.Time, next int64) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.opts.MaxSize > 0 && l.size+next > l.opts.MaxSize {
+		return true
+	}
+	return l.opts.Daily && dayOf(now) != l.day
+}
+
+// rotate 把当前文件改名为带时间戳的备份并打开新文件，然后清理超出 MaxBackups 的旧备份
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return errors.Wrap(err, "failed to close audit log")
+	}
+	l.file = nil
+
+	ext := filepath.Ext(l.opts.Path)
+	base := strings.TrimSuffix(l.opts.Path, ext)
+	stamp := time.Now().UTC().Format("20060102-150405")
+	backup := fmt.Sprintf("%s-%s%s", base, stamp, ext)
+	for i := 1; fileExists(backup); i++ {
+		backup = fmt.Sprintf("%s-%s.%d%s", base, stamp, i, ext)
+	}
+	if err := os.Rename(l.opts.Path, backup); err != nil {
+		return errors.Wrap(err, "failed to rotate audit log")
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	if l.opts.MaxBackups > 0 {
+		backups, err := Backups(l.opts.Path)
+		if err != nil {
+			return err
+		}
+		for len(backups) > l.opts.MaxBackups {
+			if err := os.Remove(backups[0]); err != nil {
+				return errors.Wrap(err, "failed to remove old audit log")
+			}
+			backups = backups[1:]
+		}
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Close 关闭审计日志文件
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Backups 返回 path 已轮转的备份文件，按从旧到新排序
+func Backups(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	matches, err := filepath.Glob(globEscape(base) + "-[0-9]*" + globEscape(ext))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list audit log backups")
+	}
+	// 同一秒内轮转的备份带有序号，文件名的字典序不一定是时间顺序，因此按修改时间排序
+	modTimes := make(map[string]time.Time, len(matches))
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil {
+			modTimes[match] = info.ModTime()
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if !modTimes[matches[i]].Equal(modTimes[matches[j]]) {
+			return modTimes[matches[i]].Before(modTimes[matches[j]])
+		}
+		return matches[i] < matches[j]
+	})
+	return matches, nil
+}
+
+// globEscape 转义路径中的 glob 元字符
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,241 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		times       []time.Duration // 每条记录相对现在的时间
+		wantBackups int
+		wantCurrent int // 当前文件中的记录数
+	}{
+		{
+			name:        "no rotation",
+			opts:        Options{},
+			times:       []time.Duration{0, 0, 0},
+			wantCurrent: 3,
+		},
+		{
+			name:        "by size",
+			opts:        Options{MaxSize: 1}, // 每条记录都超过上限，除第一条外都会触发轮转
+			times:       []time.Duration{0, 0, 0},
+			wantBackups: 2,
+			wantCurrent: 1,
+		},
+		{
+			name:        "by size keeps MaxBackups",
+			opts:        Options{MaxSize: 1, MaxBackups: 2},
+			times:       []time.Duration{0, 0, 0, 0, 0},
+			wantBackups: 2,
+			wantCurrent: 1,
+		},
+		{
+			name:        "daily",
+			opts:        Options{Daily: true},
+			times:       []time.Duration{0, 0, 24 * time.Hour, 24 * time.Hour},
+			wantBackups: 1,
+			wantCurrent: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			opts := tt.opts
+			opts.Path = path
+			l, err := Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now()
+			for i, offset := range tt.times {
+				entry := Entry{Time: now.Add(offset), Session: "s", Tool: "echo", Outcome: "success", RequestID: string(rune('a' + i))}
+				if err := l.Record(entry, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			backups, err := Backups(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(backups) != tt.wantBackups {
+				t.Errorf("got %d backups %v, want %d", len(backups), backups, tt.wantBackups)
+			}
+			current, err := queryFile(path, Filter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(current) != tt.wantCurrent {
+				t.Errorf("current file has %d entries, want %d", len(current), tt.wantCurrent)
+			}
+
+			// 所有文件合起来按写入顺序保留最新的记录
+			all, err := Query(path, Filter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, e := range all {
+				ids = append(ids, e.RequestID)
+			}
+			if !slices.IsSorted(ids) {
+				t.Errorf("entries are out of order: %v", ids)
+			}
+			if last := string(rune('a' + len(tt.times) - 1)); len(ids) == 0 || ids[len(ids)-1] != last {
+				t.Errorf("entries %v should end with %s", ids, last)
+			}
+		})
+	}
+}
+
+func TestBackupsOrder(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+	// 同一秒内轮转的备份带有序号，".10" 的字典序在 ".2" 之前，但修改时间更晚
+	names := []string{
+		"audit-20240101-000000.jsonl",
+		"audit-20240101-000000.1.jsonl",
+		"audit-20240101-000000.2.jsonl",
+		"audit-20240101-000000.10.jsonl",
+		"audit-20240102-000000.jsonl",
+	}
+	base := time.Now().Add(-time.Hour)
+	for i, name := range names {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 其他文件不算备份
+	for _, name := range []string{"audit.jsonl", "audit-notes.txt", "other-20240101.jsonl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := Backups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, backup := range backups {
+		got = append(got, filepath.Base(backup))
+	}
+	if !slices.Equal(got, names) {
+		t.Errorf("backups = %v, want %v", got, names)
+	}
+}
+
+func TestRecordArguments(t *testing.T) {
+	arguments := map[string]any{"location": "1,2", "apiKey": "secret"}
+	tests := []struct {
+		name     string
+		mode     string
+		wantArgs map[string]any
+	}{
+		{name: "hash only", mode: ArgumentsHash},
+		{name: "default is hash", mode: ""},
+		{name: "redacted", mode: ArgumentsRedacted, wantArgs: map[string]any{"location": "1,2", "apiKey": "[REDACTED]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			l, err := Open(Options{Path: path, Arguments: tt.mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := l.Record(Entry{Tool: "get_weather", Outcome: "error", Error: "GET https://x?key=abc"}, arguments); err != nil {
+				t.Fatal(err)
+			}
+			l.Close()
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode := info.Mode().Perm(); mode != 0600 {
+				t.Errorf("file mode = %v, want 0600", mode)
+			}
+
+			entries, err := Query(path, Filter{})
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("entries = %v, err = %v", entries, err)
+			}
+			entry := entries[0]
+			if entry.ArgumentsHash != HashArguments(arguments) {
+				t.Errorf("hash = %s, want %s", entry.ArgumentsHash, HashArguments(arguments))
+			}
+			if len(entry.Arguments) != len(tt.wantArgs) {
+				t.Fatalf("arguments = %v, want %v", entry.Arguments, tt.wantArgs)
+			}
+			for key, want := range tt.wantArgs {
+				if entry.Arguments[key] != want {
+					t.Errorf("arguments[%s] = %v, want %v", key, entry.Arguments[key], want)
+				}
+			}
+			if entry.Error != "GET https://x?key=[REDACTED]" {
+				t.Errorf("error = %q, want the key redacted", entry.Error)
+			}
+		})
+	}
+}
+
+func TestHashArguments(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  map[string]any
+		equal bool
+	}{
+		{name: "key order", a: map[string]any{"a": 1.0, "b": 2.0}, b: map[string]any{"b": 2.0, "a": 1.0}, equal: true},
+		{name: "nil is empty", a: nil, b: map[string]any{}, equal: true},
+		{name: "different values", a: map[string]any{"a": 1.0}, b: map[string]any{"a": 2.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if equal := HashArguments(tt.a) == HashArguments(tt.b); equal != tt.equal {
+				t.Errorf("equal = %v, want %v", equal, tt.equal)
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Now()
+	entry := Entry{Time: now, Session: "s1", Client: &Client{Name: "Claude"}, Tool: "echo", Outcome: "success"}
+	tests := []struct {
+		name   string
+		filter Filter
+		match  bool
+	}{
+		{name: "empty filter", filter: Filter{}, match: true},
+		{name: "tool", filter: Filter{Tool: "echo"}, match: true},
+		{name: "other tool", filter: Filter{Tool: "other"}},
+		{name: "client is case insensitive", filter: Filter{Client: "claude"}, match: true},
+		{name: "outcome", filter: Filter{Outcome: "error"}},
+		{name: "session", filter: Filter{Session: "s2"}},
+		{name: "inside time range", filter: Filter{Since: now.Add(-time.Minute), Until: now.Add(time.Minute)}, match: true},
+		{name: "before since", filter: Filter{Since: now.Add(time.Minute)}},
+		{name: "after until", filter: Filter{Until: now.Add(-time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(entry); got != tt.match {
+				t.Errorf("Match = %v, want %v", got, tt.match)
+			}
+		})
+	}
+}
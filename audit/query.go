@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Filter 是查询审计日志的条件，零值字段不参与过滤
+type Filter struct {
+	Tool    string
+	Session string
+	Client  string // 客户端名称，不区分大小写
+	Outcome string
+	Since   time.Time
+	Until   time.Time
+}
+
+// Match 判断记录是否满足所有条件
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case f.Tool != "" && e.Tool != f.Tool:
+		return false
+	case f.Session != "" && e.Session != f.Session:
+		return false
+	case f.Client != "" && (e.Client == nil || !strings.EqualFold(e.Client.Name, f.Client)):
+		return false
+	case f.Outcome != "" && e.Outcome != f.Outcome:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Query 按时间顺序读取 path 及其已轮转的备份，返回满足条件的记录
+func Query(path string, filter Filter) ([]Entry, error) {
+	files, err := Backups(path)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, path)
+
+	var entries []Entry
+	for _, file := range files {
+		found, err := queryFile(file, filter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+	return entries, nil
+}
+
+func queryFile(path string, filter Filter) ([]Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open audit log")
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.Wrapf(err, "%s: invalid audit entry on line %d", path, line)
+		}
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	return entries, nil
+}
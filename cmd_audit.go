@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/audit"
+)
+
+const auditUsage = `Usage:
+  mcp-server-demo audit query [-config path | -file path] [filters] [-json] [-limit n]
+`
+
+// runAudit 实现 audit 子命令
+func runAudit(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, auditUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "query":
+		return runAuditQuery(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown audit command %q\n\n%s", args[0], auditUsage)
+		return 2
+	}
+}
+
+// runAuditQuery 按条件查询审计日志，包括已轮转的文件
+func runAuditQuery(args []string) int {
+	fs := flag.NewFlagSet("audit query", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the JSON config file, used to find audit.path (default $MCP_SERVER_CONFIG)")
+	file := fs.String("file", "", "path to the audit log (overrides audit.path from the config file)")
+	var filter audit.Filter
+	fs.StringVar(&filter.Tool, "tool", "", "only show calls of this tool")
+	fs.StringVar(&filter.Session, "session", "", "only show calls from this session (the session digest shown by /debug/status)")
+	fs.StringVar(&filter.Client, "client", "", "only show calls from clients with this name")
+	fs.StringVar(&filter.Outcome, "outcome", "", "only show calls with this outcome: success, error, timeout, rate_limited, panic, not_found or rejected")
+	since := fs.String("since", "", "only show calls at or after this time (RFC 3339, YYYY-MM-DD or a duration such as 24h)")
+	until := fs.String("until", "", "only show calls before this time (same formats as -since)")
+	asJSON := fs.Bool("json", false, "print matching entries as JSON lines")
+	limit := fs.Int("limit", 0, "only show the last n matching entries")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var err error
+	if filter.Since, err = parseQueryTime(*since); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -since: %v\n", err)
+		return 2
+	}
+	if filter.Until, err = parseQueryTime(*until); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -until: %v\n", err)
+		return 2
+	}
+
+	path := *file
+	if path == "" {
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		path = cfg.Audit.Path
+	}
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "no audit log configured, set audit.path in the config file or pass -file")
+		return 1
+	}
+
+	entries, err := audit.Query(path, filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[len(entries)-*limit:]
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to marshal output: %v\n", err)
+				return 1
+			}
+		}
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSESSION\tCLIENT\tTOOL\tOUTCOME\tDURATION\tARGUMENTS\tERROR")
+	for _, entry := range entries {
+		client := "-"
+		if entry.Client != nil {
+			client = strings.TrimSuffix(entry.Client.Name+"/"+entry.Client.Version, "/")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%.1fms\t%s\t%s\n",
+			entry.Time.Format(time.RFC3339), entry.Session, client, entry.Tool, entry.Outcome,
+			entry.DurationMs, shortHash(entry.ArgumentsHash), entry.Error)
+	}
+	w.Flush()
+	return 0
+}
+
+// parseQueryTime 解析 RFC 3339 时间、日期 (UTC) 或表示多久之前的时长，空字符串返回零值
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a time, date or duration", value)
+}
+
+// shortHash 缩短参数摘要以便在表格中显示
+func shortHash(hash string) string {
+	const width = len("sha256:") + 12
+	if len(hash) > width {
+		return hash[:width]
+	}
+	return hash
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/n8sPxD/mcp-server-demo/audit"
+	"github.com/n8sPxD/mcp-server-demo/config"
+	"github.com/n8sPxD/mcp-server-demo/server"
+	"github.com/n8sPxD/mcp-server-demo/tools"
+	"github.com/pkg/errors"
+)
+
+// replayStats 汇总一次重放中响应的比较结果
+type replayStats struct {
+	sessions   int
+	matched    int
+	differed   int
+	missing    int // 录制中有而重放时没有产生的响应
+	unexpected int // 重放时产生而录制中没有的响应
+}
+
+func (s replayStats) failed() bool {
+	return s.differed+s.missing+s.unexpected > 0
+}
+
+// runReplay 实现 replay 子命令：把录制的会话重新送入 MCPServer，并把实际响应与录制的响应逐一比较
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the JSON config file (default $MCP_SERVER_CONFIG)")
+	sessionID := fs.String("session", "", "only replay the session with this ID")
+	live := fs.Bool("live", false, "start plugins and downstream servers and call external services instead of answering those tools from the recording")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: mcp-server-demo replay [-config path] [-session id] [-live] <recording.jsonl>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	entries, err := server.ReadRecording(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !*live {
+		// 离线重放不启动插件、不挂载下游服务器，这些工具由录制的结果代替
+		offline := *cfg
+		offline.Plugins, offline.PluginDir, offline.Downstream = nil, "", nil
+		cfg = &offline
+	}
+
+	// 按会话分组，保持会话首次出现的顺序
+	var order []string
+	sessions := make(map[string][]server.RecordEntry)
+	for _, entry := range entries {
+		if *sessionID != "" && entry.Session != *sessionID {
+			continue
+		}
+		if _, ok := sessions[entry.Session]; !ok {
+			order = append(order, entry.Session)
+		}
+		sessions[entry.Session] = append(sessions[entry.Session], entry)
+	}
+	if len(order) == 0 {
+		fmt.Fprintln(os.Stderr, "no sessions to replay")
+		return 1
+	}
+
+	var stats replayStats
+	for _, id := range order {
+		if err := replaySession(cfg, id, sessions[id], *live, &stats); err != nil {
+			fmt.Fprintf(os.Stderr, "session %s: %v\n", id, err)
+			return 1
+		}
+	}
+
+	fmt.Printf("replayed %d session(s): %d response(s) matched, %d differed, %d missing, %d unexpected\n",
+		stats.sessions, stats.matched, stats.differed, stats.missing, stats.unexpected)
+	if stats.failed() {
+		return 1
+	}
+	return 0
+}
+
+// replaySession 在一个新的服务器上重放一个会话收到的消息，并比较响应。
+// 响应按 JSON-RPC ID 配对，通知只用于驱动会话，不参与比较。
+// live 为 false 时访问外部服务的工具返回录制中的结果，见 replayStubs
+func replaySession(cfg *config.Config, id string, entries []server.RecordEntry, live bool, stats *replayStats) error {
+	out := &captureWriter{}
+	a, err := newApp(cfg, nil, out)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	if !live {
+		newReplayStubs(entries).install(a.server.Registry())
+	}
+
+	recorded := make(map[string][]byte)
+	var recordedOrder []string
+	for _, entry := range entries {
+		switch entry.Direction {
+		case server.DirectionReceived:
+			a.server.ProcessMessage(entry.Payload())
+		case server.DirectionSent:
+			if responseID, ok := responseKey(entry.Payload()); ok {
+				recorded[responseID] = entry.Payload()
+				recordedOrder = append(recordedOrder, responseID)
+			}
+		}
+	}
+
+	actual := make(map[string][]byte)
+	for _, line := range out.lines() {
+		if responseID, ok := responseKey(line); ok {
+			actual[responseID] = line
+		}
+	}
+
+	stats.sessions++
+	for _, responseID := range recordedOrder {
+		got, ok := actual[responseID]
+		if !ok {
+			stats.missing++
+			fmt.Printf("session %s: response %s missing\n", id, responseID)
+			continue
+		}
+		delete(actual, responseID)
+
+		diffs := diffMessages(recorded[responseID], got)
+		if len(diffs) == 0 {
+			stats.matched++
+			continue
+		}
+		stats.differed++
+		fmt.Printf("session %s: response %s differs\n", id, responseID)
+		for _, diff := range diffs {
+			fmt.Printf("    %s\n", diff)
+		}
+	}
+
+	unexpected := make([]string, 0, len(actual))
+	for responseID := range actual {
+		unexpected = append(unexpected, responseID)
+	}
+	sort.Strings(unexpected)
+	for _, responseID := range unexpected {
+		stats.unexpected++
+		fmt.Printf("session %s: unexpected response %s: %s\n", id, responseID, actual[responseID])
+	}
+	return nil
+}
+
+// responseKey 返回响应消息的 ID，不是响应时返回 false
+func responseKey(message []byte) (string, bool) {
+	var probe struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(message, &probe); err != nil || len(probe.ID) == 0 || probe.Method != "" {
+		return "", false
+	}
+	return compactID(probe.ID), true
+}
+
+// compactID 返回 JSON-RPC ID 的紧凑形式，用于配对请求和响应
+func compactID(id json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, id); err != nil {
+		return string(id)
+	}
+	return compact.String()
+}
+
+// replayStubs 保存录制中每次工具调用的结果。离线重放时，访问外部服务的工具
+// (openWorldHint 不为 false) 和本地不存在的工具 (来自插件或下游服务器) 都由这些结果代替，
+// 重放不会访问真实的外部服务，结果也不会因外部数据变化而不同
+type replayStubs struct {
+	mu      sync.Mutex
+	results map[string][]*tools.ExecuteToolResult // 工具名和参数摘要 -> 按调用顺序排列的结果
+	defs    map[string]tools.ToolDefinition       // 录制的 tools/list 响应和 tools/call 请求中出现的工具
+}
+
+func stubKey(name string, arguments map[string]any) string {
+	return name + " " + audit.HashArguments(arguments)
+}
+
+func newReplayStubs(entries []server.RecordEntry) *replayStubs {
+	stubs := &replayStubs{
+		results: make(map[string][]*tools.ExecuteToolResult),
+		defs:    make(map[string]tools.ToolDefinition),
+	}
+	calls := make(map[string]tools.ExecuteToolParams) // 请求 ID -> 尚未收到响应的 tools/call
+	lists := make(map[string]bool)                    // 请求 ID -> 尚未收到响应的 tools/list
+
+	for _, entry := range entries {
+		switch entry.Direction {
+		case server.DirectionReceived:
+			var request struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if err := json.Unmarshal(entry.Payload(), &request); err != nil || len(request.ID) == 0 {
+				continue
+			}
+			switch request.Method {
+			case "tools/call":
+				var params tools.ExecuteToolParams
+				if err := json.Unmarshal(request.Params, &params); err == nil {
+					calls[compactID(request.ID)] = params
+					if _, ok := stubs.defs[params.ToolName]; !ok {
+						stubs.defs[params.ToolName] = tools.ToolDefinition{Name: params.ToolName, InputSchema: tools.ToolParameters{Type: "object"}}
+					}
+				}
+			case "tools/list":
+				lists[compactID(request.ID)] = true
+			}
+		case server.DirectionSent:
+			responseID, ok := responseKey(entry.Payload())
+			if !ok {
+				continue
+			}
+			var response struct {
+				Result json.RawMessage `json:"result"`
+			}
+			if err := json.Unmarshal(entry.Payload(), &response); err != nil {
+				continue
+			}
+			if params, ok := calls[responseID]; ok {
+				delete(calls, responseID)
+				var result tools.ExecuteToolResult
+				if len(response.Result) > 0 && json.Unmarshal(response.Result, &result) == nil {
+					key := stubKey(params.ToolName, params.Inputs)
+					stubs.results[key] = append(stubs.results[key], &result)
+				}
+			} else if lists[responseID] {
+				delete(lists, responseID)
+				var list server.ListToolsResult
+				if len(response.Result) > 0 && json.Unmarshal(response.Result, &list) == nil {
+					for _, def := range list.Tools {
+						stubs.defs[def.Name] = def
+					}
+				}
+			}
+		}
+	}
+	return stubs
+}
+
+// install 把注册表中访问外部服务的工具替换为桩，并为录制中出现而本地不存在的工具注册桩
+func (r *replayStubs) install(registry *tools.Registry) {
+	registry.Batch(func() {
+		for _, def := range registry.List() {
+			if openWorld(def) {
+				registry.Register(def, r.call(def.Name))
+			}
+		}
+		for name, def := range r.defs {
+			if _, ok := registry.GetTool(name); !ok {
+				registry.Register(def, r.call(name))
+			}
+		}
+	})
+}
+
+// call 返回按顺序给出录制结果的工具实现
+func (r *replayStubs) call(name string) tools.ToolFunc {
+	return func(ctx context.Context, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		key := stubKey(name, inputs)
+		results := r.results[key]
+		if len(results) == 0 {
+			return nil, errors.Errorf("no recorded result for tool '%s' with these arguments", name)
+		}
+		r.results[key] = results[1:]
+		return results[0], nil
+	}
+}
+
+// openWorld 判断工具是否可能访问外部服务。没有标注 openWorldHint 时按 MCP 规范视为 true
+func openWorld(def tools.ToolDefinition) bool {
+	return def.Annotations == nil || def.Annotations.OpenWorldHint == nil || *def.Annotations.OpenWorldHint
+}
+
+// diffMessages 比较两条 JSON 消息，返回形如 "path: recorded X, actual Y" 的差异列表
+func diffMessages(recorded, actual []byte) []string {
+	var want, got any
+	if err := json.Unmarshal(recorded, &want); err != nil {
+		return []string{fmt.Sprintf("recorded message is not valid JSON: %v", err)}
+	}
+	if err := json.Unmarshal(actual, &got); err != nil {
+		return []string{fmt.Sprintf("actual message is not valid JSON: %v", err)}
+	}
+	var diffs []string
+	diffJSON("$", want, got, &diffs)
+	return diffs
+}
+
+func diffJSON(path string, want, got any, diffs *[]string) {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range w {
+			keys[k] = true
+		}
+		for k := range g {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			wv, wok := w[k]
+			gv, gok := g[k]
+			switch {
+			case !gok:
+				*diffs = append(*diffs, fmt.Sprintf("%s.%s: recorded %s, actual <missing>", path, k, jsonText(wv)))
+			case !wok:
+				*diffs = append(*diffs, fmt.Sprintf("%s.%s: recorded <missing>, actual %s", path, k, jsonText(gv)))
+			default:
+				diffJSON(path+"."+k, wv, gv, diffs)
+			}
+		}
+		return
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			break
+		}
+		for i := range w {
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], diffs)
+		}
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: recorded %s, actual %s", path, jsonText(want), jsonText(got)))
+	}
+}
+
+func jsonText(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// captureWriter 收集服务器在 stdio 会话上写出的消息
+type captureWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+// lines 返回目前为止写出的所有非空行
+func (c *captureWriter) lines() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var lines [][]byte
+	for _, line := range strings.Split(c.buf.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, []byte(line))
+		}
+	}
+	return lines
+}
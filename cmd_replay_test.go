@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/n8sPxD/mcp-server-demo/server"
+	"github.com/n8sPxD/mcp-server-demo/tools"
+)
+
+func TestDiffMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		recorded string
+		actual   string
+		want     []string
+	}{
+		{name: "equal", recorded: `{"id":1,"result":{"a":1}}`, actual: `{"result":{"a":1},"id":1}`},
+		{name: "changed value", recorded: `{"result":{"a":1}}`, actual: `{"result":{"a":2}}`,
+			want: []string{"$.result.a: recorded 1, actual 2"}},
+		{name: "missing and extra keys", recorded: `{"result":{"a":1}}`, actual: `{"result":{"b":1}}`,
+			want: []string{"$.result.a: recorded 1, actual <missing>", "$.result.b: recorded <missing>, actual 1"}},
+		{name: "array element", recorded: `{"content":[{"text":"x"},{"text":"y"}]}`, actual: `{"content":[{"text":"x"},{"text":"z"}]}`,
+			want: []string{`$.content[1].text: recorded "y", actual "z"`}},
+		{name: "array length", recorded: `{"content":[1]}`, actual: `{"content":[1,2]}`,
+			want: []string{"$.content: recorded [1], actual [1,2]"}},
+		{name: "type change", recorded: `{"result":{}}`, actual: `{"result":[]}`,
+			want: []string{"$.result: recorded {}, actual []"}},
+		{name: "invalid actual", recorded: `{}`, actual: `nope`,
+			want: []string{"actual message is not valid JSON: invalid character 'o' in literal null (expecting 'u')"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffMessages([]byte(tt.recorded), []byte(tt.actual))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseKey(t *testing.T) {
+	tests := []struct {
+		message string
+		key     string
+		ok      bool
+	}{
+		{message: `{"jsonrpc":"2.0","id":1,"result":{}}`, key: "1", ok: true},
+		{message: `{"jsonrpc":"2.0","id": "a b","error":{}}`, key: `"a b"`, ok: true},
+		{message: `{"jsonrpc":"2.0","id":1,"method":"ping"}`},
+		{message: `{"jsonrpc":"2.0","method":"notifications/message"}`},
+		{message: `not json`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			key, ok := responseKey([]byte(tt.message))
+			if key != tt.key || ok != tt.ok {
+				t.Errorf("got %q, %v; want %q, %v", key, ok, tt.key, tt.ok)
+			}
+		})
+	}
+}
+
+func recordEntry(direction, message string) server.RecordEntry {
+	return server.RecordEntry{Direction: direction, Session: "s", Message: json.RawMessage(message)}
+}
+
+func TestReplayStubs(t *testing.T) {
+	entries := []server.RecordEntry{
+		recordEntry(server.DirectionReceived, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`),
+		recordEntry(server.DirectionSent, `{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"text.stats","inputSchema":{"type":"object","properties":{}}}]}}`),
+		recordEntry(server.DirectionReceived, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"get_weather","arguments":{"location":"1,2"}}}`),
+		recordEntry(server.DirectionSent, `{"jsonrpc":"2.0","id":2,"result":{"content":[{"type":"text","text":"sunny"}]}}`),
+		recordEntry(server.DirectionReceived, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"get_weather","arguments":{"location":"1,2"}}}`),
+		recordEntry(server.DirectionSent, `{"jsonrpc":"2.0","id":3,"result":{"content":[{"type":"text","text":"rainy"}]}}`),
+		// 重复使用的请求 ID 对应新的调用
+		recordEntry(server.DirectionReceived, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"text.stats","arguments":{"text":"a b"}}}`),
+		recordEntry(server.DirectionSent, `{"jsonrpc":"2.0","id":2,"result":{"content":[{"type":"text","text":"2 words"}]}}`),
+	}
+
+	registry := tools.NewDefaultRegistry()
+	newReplayStubs(entries).install(registry)
+
+	tests := []struct {
+		tool    string
+		inputs  map[string]any
+		want    string
+		wantErr bool
+	}{
+		{tool: "get_weather", inputs: map[string]any{"location": "1,2"}, want: "sunny"},
+		{tool: "get_weather", inputs: map[string]any{"location": "1,2"}, want: "rainy"},
+		{tool: "get_weather", inputs: map[string]any{"location": "1,2"}, wantErr: true}, // 录制的结果已用完
+		{tool: "get_weather", inputs: map[string]any{"location": "3,4"}, wantErr: true},
+		{tool: "text.stats", inputs: map[string]any{"text": "a b"}, want: "2 words"},
+		{tool: "caculator", inputs: map[string]any{"operation": "add", "num1": 1.0, "num2": 2.0},
+			want: "The result of add 1.000000 and 2.000000 is 3.000000"}, // 不访问外部服务的工具照常执行
+	}
+	for i, tt := range tests {
+		result, err := registry.Call(context.Background(), tt.tool, tt.inputs)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("call %d (%s): err = %v, wantErr %v", i, tt.tool, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if len(result.Content) == 0 {
+			t.Fatalf("call %d (%s): empty result", i, tt.tool)
+		}
+		if text, ok := result.Content[0].(*tools.TextContent); !ok || text.Text != tt.want {
+			t.Errorf("call %d (%s): got %+v, want %q", i, tt.tool, result.Content, tt.want)
+		}
+	}
+}
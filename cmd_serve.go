@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/audit"
+	"github.com/n8sPxD/mcp-server-demo/config"
+	"github.com/n8sPxD/mcp-server-demo/metrics"
+	"github.com/n8sPxD/mcp-server-demo/server"
+	"github.com/n8sPxD/mcp-server-demo/tracing"
+)
+
+// runServe 实现 serve 子命令：按配置的传输方式启动 MCP 服务器
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the JSON config file (default $MCP_SERVER_CONFIG)")
+	transport := fs.String("transport", "", "transport to serve on: stdio or http (overrides the config file)")
+	listen := fs.String("listen", "", "listen address of the http transport, e.g. 127.0.0.1:8080")
+	record := fs.String("record", "", "append every inbound and outbound JSON-RPC message to this JSONL file")
+	metricsListen := fs.String("metrics-listen", "", "serve Prometheus metrics on /metrics at this address (overrides the config file)")
+	debugListen := fs.String("debug-listen", "", "serve /debug/status at this loopback address (overrides the config file)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	fmt.Fprintln(os.Stderr, "DEBUG: MCP server started")
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// 命令行参数覆盖配置文件中的传输方式，重新加载配置时同样适用
+	adjust := func(cfg *config.Config) {
+		if *transport != "" {
+			cfg.Transports = []config.TransportConfig{{Type: *transport, Listen: *listen}}
+		} else if *listen != "" {
+			for i := range cfg.Transports {
+				if cfg.Transports[i].Type == config.TransportHTTP {
+					cfg.Transports[i].Listen = *listen
+				}
+			}
+		}
+		if *metricsListen != "" {
+			cfg.Metrics.Listen = *metricsListen
+		}
+		if *debugListen != "" {
+			cfg.Debug.Listen = *debugListen
+		}
+	}
+	adjust(cfg)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	stdinReader := bufio.NewReader(os.Stdin)
+	stdoutWriter := bufio.NewWriter(os.Stdout)
+
+	a, err := newApp(cfg, stdinReader, stdoutWriter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer a.Close()
+
+	if *record != "" {
+		recorder, err := server.OpenRecorder(*record, cfg.Redactor())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		a.server.SetRecorder(recorder)
+		defer func() {
+			a.server.SetRecorder(nil)
+			recorder.Close()
+		}()
+	}
+
+	if cfg.Audit.Path != "" {
+		auditLog, err := audit.Open(cfg.AuditOptions())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		a.server.SetAuditLog(auditLog)
+		defer func() {
+			a.server.SetAuditLog(nil)
+			auditLog.Close()
+		}()
+	}
+
+	exporter, err := cfg.TraceExporter()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if exporter != nil {
+		provider := tracing.NewProvider(exporter, a.logger.With("component", "tracing"))
+		tracing.SetProvider(provider)
+		defer func() {
+			tracing.SetProvider(nil)
+			if err := provider.Shutdown(); err != nil {
+				a.logger.Error("Failed to shut down tracing", "error", err)
+			}
+		}()
+	}
+
+	logger, server := a.logger, a.server
+	logger.Info("MCP server instance created, waiting for messages")
+
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	if path := resolveConfigPath(*configPath); path != "" && cfg.Reload.Enabled {
+		go a.watchConfig(path, adjust, stopWatching)
+	}
+
+	if cfg.Metrics.Listen != "" {
+		go func(listen string) {
+			logger.Info("Serving metrics", "listen", listen)
+			if err := metrics.Default.ListenAndServe(listen); err != nil {
+				logger.Error("Metrics endpoint stopped", "error", err)
+				fmt.Fprintf(os.Stderr, "Metrics endpoint stopped: %v\n", err)
+			}
+		}(cfg.Metrics.Listen)
+	}
+	if cfg.Debug.Listen != "" {
+		go func(listen string) {
+			logger.Info("Serving debug endpoints", "listen", listen)
+			if err := server.ListenAndServeDebug(listen); err != nil {
+				logger.Error("Debug endpoint stopped", "error", err)
+				fmt.Fprintf(os.Stderr, "Debug endpoint stopped: %v\n", err)
+			}
+		}(cfg.Debug.Listen)
+	}
+
+	for _, t := range cfg.Transports {
+		switch t.Type {
+		case config.TransportStdio:
+			go func() {
+				scanner := bufio.NewScanner(stdinReader)
+				logger.Debug("Scanner created, entering scan loop")
+
+				for scanner.Scan() {
+					messageBytes := scanner.Bytes()
+					if len(bytes.TrimSpace(messageBytes)) == 0 {
+						logger.Debug("Received an empty line, skipping")
+						continue
+					}
+
+					server.ProcessMessage(messageBytes)
+				}
+
+				if err := scanner.Err(); err != nil {
+					logger.Error("Error reading from stdin", "error", err)
+				}
+				logger.Info("Stdin scanner finished")
+				// 如果输入结束，也应该关闭服务器
+				server.Shutdown()
+			}()
+		case config.TransportHTTP:
+			server.SetAllowedOrigins(t.AllowedOrigins)
+			server.SetSessionIdleTimeout(time.Duration(t.SessionIdleTimeout))
+			go func(listen string) {
+				if err := server.ListenAndServe(listen); err != nil {
+					logger.Error("HTTP transport stopped", "error", err)
+					fmt.Fprintf(os.Stderr, "HTTP transport stopped: %v\n", err)
+					server.Shutdown()
+				}
+			}(t.Listen)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	// 等待服务器关闭信号
+	select {
+	case <-server.ShutdownSignal:
+	case sig := <-signals:
+		logger.Info("Received signal, shutting down", "signal", sig.String())
+	}
+	logger.Info("MCP server shut down gracefully")
+	return 0
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/n8sPxD/mcp-server-demo/tools"
+)
+
+// cliSessionID 是命令行直接调用工具时使用的会话 ID
+const cliSessionID = "cli"
+
+const toolsUsage = `Usage:
+  mcp-server-demo tools list [-config path]
+  mcp-server-demo tools call <name> [-config path] [-arg key=value]... [-args json]
+`
+
+// runTools 实现 tools 子命令
+func runTools(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, toolsUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "list":
+		return runToolsList(args[1:])
+	case "call":
+		return runToolsCall(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown tools command %q\n\n%s", args[0], toolsUsage)
+		return 2
+	}
+}
+
+// runToolsList 打印所有已注册工具的定义
+func runToolsList(args []string) int {
+	fs := flag.NewFlagSet("tools list", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the JSON config file (default $MCP_SERVER_CONFIG)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	a, err := newCLIApp(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer a.Close()
+
+	return printJSON(a.server.Registry().List())
+}
+
+// argList 收集可重复的 -arg key=value 参数
+type argList map[string]any
+
+func (a argList) String() string {
+	return fmt.Sprint(map[string]any(a))
+}
+
+// Set 解析 key=value。value 是合法的 JSON 时按 JSON 解析 (数字、布尔值、对象等)，否则作为字符串
+func (a argList) Set(value string) error {
+	key, raw, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("argument %q must be in key=value form", value)
+	}
+
+	var parsed any
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		parsed = raw
+	}
+	a[key] = parsed
+	return nil
+}
+
+// runToolsCall 不经过 MCP 宿主直接调用一个工具，并打印结果
+func runToolsCall(args []string) int {
+	// 工具名可以写在参数之前
+	name := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	inputs := argList{}
+	fs := flag.NewFlagSet("tools call", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the JSON config file (default $MCP_SERVER_CONFIG)")
+	rawArgs := fs.String("args", "", "tool arguments as a JSON object, merged before -arg values")
+	fs.Var(inputs, "arg", "tool argument as key=value, may be repeated; JSON values are decoded")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		fmt.Fprint(os.Stderr, toolsUsage)
+		return 2
+	}
+
+	arguments := map[string]any{}
+	if *rawArgs != "" {
+		if err := json.Unmarshal([]byte(*rawArgs), &arguments); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -args: %v\n", err)
+			return 2
+		}
+	}
+	for key, value := range inputs {
+		arguments[key] = value
+	}
+
+	a, err := newCLIApp(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer a.Close()
+
+	ctx := tools.WithSessionID(context.Background(), cliSessionID)
+	result, err := a.server.Registry().Call(ctx, name, arguments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tool call failed: %v\n", err)
+		return 1
+	}
+	if code := printJSON(result); code != 0 {
+		return code
+	}
+	if result.IsError {
+		return 1
+	}
+	return 0
+}
+
+// newCLIApp 按配置组装一个不绑定任何传输方式的服务器
+func newCLIApp(configPath string) (*app, error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return newApp(cfg, nil, nil)
+}
+
+func printJSON(v any) int {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to marshal output: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
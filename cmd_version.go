@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// runVersion 实现 version 子命令
+func runVersion(args []string) int {
+	fmt.Printf("mcp-server-demo %s (%s %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return 0
+}
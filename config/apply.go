@@ -0,0 +1,155 @@
+package config
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/audit"
+	"github.com/n8sPxD/mcp-server-demo/logging"
+	"github.com/n8sPxD/mcp-server-demo/tools"
+	"github.com/n8sPxD/mcp-server-demo/tracing"
+	"github.com/pkg/errors"
+)
+
+// ApplyTools 把工具设置应用到注册表。某个工具的设置失败 (例如工具不存在) 不会影响其他工具，
+// 所有失败都会被返回。
+//
+// prev 是之前已应用的配置，为 nil 时应用全部设置。重新加载配置时只有与 prev 相比发生变化的工具设置，
+// 以及 refreshed 中重新注册过的工具 (重新注册会丢失原有设置) 才会被重新应用，
+// 以免重置未变化工具的限流和缓存状态。prev 中有而当前配置中已删除的工具恢复默认设置
+func (c *Config) ApplyTools(registry *tools.Registry, prev *Config, refreshed []string) []error {
+	var errs []error
+	if prev == nil {
+		prev = &Config{}
+	}
+
+	defaultTimeout := tools.DefaultToolTimeout
+	if c.Tools.DefaultTimeout != nil {
+		defaultTimeout = time.Duration(*c.Tools.DefaultTimeout)
+	}
+	registry.SetDefaultTimeout(defaultTimeout)
+	if !reflect.DeepEqual(c.Tools.Aliases, prev.Tools.Aliases) {
+		registry.SetAliases(c.Tools.Aliases)
+	}
+
+	isRefreshed := make(map[string]bool, len(refreshed))
+	for _, name := range refreshed {
+		isRefreshed[name] = true
+	}
+
+	for _, name := range sortedKeys(c.Tools.Settings) {
+		tc := c.Tools.Settings[name]
+		if tc == nil {
+			tc = &ToolConfig{}
+		}
+		if old := prev.Tools.Settings[name]; old != nil && reflect.DeepEqual(old, tc) && !isRefreshed[name] {
+			continue
+		}
+		if err := applyToolConfig(registry, name, tc); err != nil {
+			errs = append(errs, errors.Wrapf(err, "tools.settings.%s", name))
+		}
+	}
+	for _, name := range sortedKeys(prev.Tools.Settings) {
+		if _, ok := c.Tools.Settings[name]; ok {
+			continue
+		}
+		// 工具可能已经随插件或下游服务器一起被移除，此时无需恢复
+		_ = applyToolConfig(registry, name, &ToolConfig{})
+	}
+	return errs
+}
+
+func applyToolConfig(registry *tools.Registry, name string, tc *ToolConfig) error {
+	enabled := tc.Enabled == nil || *tc.Enabled
+	if err := registry.SetEnabled(name, enabled); err != nil {
+		return err
+	}
+	if err := registry.SetTimeout(name, time.Duration(tc.Timeout)); err != nil {
+		return err
+	}
+	if err := registry.SetRateLimit(name, tc.RateLimit.toRateLimit()); err != nil {
+		return err
+	}
+	if err := registry.SetSessionRateLimit(name, tc.SessionRateLimit.toRateLimit()); err != nil {
+		return err
+	}
+	if tc.Cache == nil {
+		registry.DisableCache(name)
+		return nil
+	}
+	return registry.EnableCache(name, tools.CacheOptions{TTL: time.Duration(tc.Cache.TTL), MaxEntries: tc.Cache.MaxEntries})
+}
+
+// WeatherGetter 按配置创建 get_weather 使用的天气服务，provider 为 auto 时返回 nil。
+// 所选服务缺少 API key 时返回错误
+func (c *Config) WeatherGetter() (tools.WeatherGetter, error) {
+	getter, err := tools.NewWeatherGetter(c.Weather.Provider, c.Weather.APIKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "weather")
+	}
+	return getter, nil
+}
+
+// ApplyWeather 按配置选择 get_weather 使用的天气服务
+func (c *Config) ApplyWeather() error {
+	getter, err := c.WeatherGetter()
+	if err != nil {
+		return err
+	}
+	tools.SetWeatherGetter(getter)
+	return nil
+}
+
+// LoggingOptions 返回按配置创建日志记录器的选项，level 用于在运行时调整日志级别
+func (c *Config) LoggingOptions(level *slog.LevelVar) logging.Options {
+	opts := logging.Options{Level: level, Format: strings.ToLower(c.Logging.Format)}
+	if c.Logging.Redact != nil {
+		opts.RedactKeys = c.Logging.Redact.Keys
+		opts.RedactQueryParams = c.Logging.Redact.QueryParams
+	}
+	return opts
+}
+
+// ApplyLogLevel 把配置中的日志级别设置到 level 上
+func (c *Config) ApplyLogLevel(level *slog.LevelVar) error {
+	l, err := logging.ParseLevel(c.Logging.Level)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// TraceExporter 按 tracing 设置创建 span 导出器，没有开启追踪时返回 nil
+func (c *Config) TraceExporter() (tracing.Exporter, error) {
+	switch c.Tracing.Exporter {
+	case TraceExporterFile:
+		return tracing.NewFileExporter(c.Tracing.Path, c.Server.Name)
+	case TraceExporterOTLP:
+		return tracing.NewOTLPExporter(c.Tracing.Endpoint, c.Server.Name, c.Tracing.Headers), nil
+	}
+	return nil, nil
+}
+
+// Redactor 返回按 logging.redact 配置的脱敏规则，用于日志之外同样需要脱敏的输出
+func (c *Config) Redactor() *logging.Redactor {
+	var keys, queryParams []string
+	if c.Logging.Redact != nil {
+		keys, queryParams = c.Logging.Redact.Keys, c.Logging.Redact.QueryParams
+	}
+	return logging.NewRedactor(keys, queryParams)
+}
+
+// AuditOptions 返回审计日志的设置，参数和错误信息使用与日志相同的脱敏规则
+func (c *Config) AuditOptions() audit.Options {
+	return audit.Options{
+		Path:       c.Audit.Path,
+		MaxSize:    int64(c.Audit.MaxSizeMB) << 20,
+		Daily:      c.Audit.RotateDaily,
+		MaxBackups: c.Audit.MaxBackups,
+		Arguments:  c.Audit.Arguments,
+		Redactor:   c.Redactor(),
+	}
+}
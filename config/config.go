@@ -0,0 +1,426 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/audit"
+	"github.com/n8sPxD/mcp-server-demo/logging"
+	"github.com/n8sPxD/mcp-server-demo/proxy"
+	"github.com/n8sPxD/mcp-server-demo/server"
+	"github.com/n8sPxD/mcp-server-demo/tools"
+	"github.com/pkg/errors"
+)
+
+// 传输方式
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http" // Streamable HTTP，需要设置 listen
+)
+
+// Config 是服务器的完整配置
+type Config struct {
+	Server     ServerConfig         `json:"server"`
+	Transports []TransportConfig    `json:"transports"`
+	Logging    LoggingConfig        `json:"logging"`
+	Tools      ToolsConfig          `json:"tools"`
+	Weather    WeatherConfig        `json:"weather"`
+	Plugins    []tools.PluginConfig `json:"plugins,omitempty"`
+	PluginDir  string               `json:"pluginDir,omitempty"`  // 目录中的每个可执行文件都作为一个插件加载
+	Downstream []proxy.ServerConfig `json:"downstream,omitempty"` // 要挂载的下游 MCP 服务器
+	Reload     ReloadConfig         `json:"reload"`
+	Metrics    MetricsConfig        `json:"metrics"`
+	Debug      DebugConfig          `json:"debug"`
+	Tracing    TracingConfig        `json:"tracing"`
+	Audit      AuditConfig          `json:"audit"`
+}
+
+// ServerConfig 是服务器的身份信息和协议相关设置
+type ServerConfig struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	PageSize int    `json:"pageSize,omitempty"` // list 类方法的分页大小
+	Trace    string `json:"trace,omitempty"`    // initialize 中没有指定 trace 的会话使用的级别："off"、"messages" 或 "verbose"，默认 "off"
+}
+
+// TransportConfig 描述一种传输方式
+type TransportConfig struct {
+	Type               string   `json:"type"`                         // "stdio" 或 "http"
+	Listen             string   `json:"listen,omitempty"`             // 网络传输的监听地址
+	AllowedOrigins     []string `json:"allowedOrigins,omitempty"`     // 回环地址之外允许访问 http 传输的浏览器来源
+	SessionIdleTimeout Duration `json:"sessionIdleTimeout,omitempty"` // 会话空闲多久后被关闭，默认 30 分钟
+}
+
+// LoggingConfig 是日志设置
+type LoggingConfig struct {
+	Path   string        `json:"path"`             // 日志文件路径
+	Level  string        `json:"level,omitempty"`  // "debug"、"info"、"warn" 或 "error"，默认 "info"
+	Format string        `json:"format,omitempty"` // "text" 或 "json"，默认 "text"
+	Redact *RedactConfig `json:"redact,omitempty"`
+}
+
+// RedactConfig 列出在默认列表之外需要在日志中脱敏的内容，见 logging.DefaultRedactKeys
+type RedactConfig struct {
+	Keys        []string `json:"keys,omitempty"`        // 属性名和 JSON 字段名，不区分大小写
+	QueryParams []string `json:"queryParams,omitempty"` // URL 查询参数
+}
+
+// ReloadConfig 是配置热加载的设置。开启后服务器会定期检查配置文件和插件目录，
+// 并在不重启的情况下应用变化。传输方式和日志设置的变化需要重启才能生效
+type ReloadConfig struct {
+	Enabled  bool     `json:"enabled"`
+	Interval Duration `json:"interval,omitempty"` // 检查间隔，默认为 DefaultReloadInterval
+}
+
+// DefaultReloadInterval 是未设置 reload.interval 时检查配置变化的间隔
+const DefaultReloadInterval = 2 * time.Second
+
+// MetricsConfig 是指标端点的设置。listen 非空时在该地址的 /metrics 上以 Prometheus 文本格式提供指标，
+// 只应监听本地地址。监听地址的变化需要重启才能生效
+type MetricsConfig struct {
+	Listen string `json:"listen,omitempty"` // 例如 "127.0.0.1:9090"，为空时不提供指标端点
+}
+
+// DebugConfig 是调试端点的设置。listen 非空时在该地址的 /debug/status 上提供服务器状态，
+// 其中有会话和客户端信息，只允许监听回环地址。监听地址的变化需要重启才能生效
+type DebugConfig struct {
+	Listen string `json:"listen,omitempty"` // 例如 "127.0.0.1:9465"，为空时不提供调试端点
+}
+
+// 追踪数据的导出方式
+const (
+	TraceExporterFile = "file" // 以 OTLP JSON 格式追加写入 tracing.path
+	TraceExporterOTLP = "otlp" // 以 OTLP/HTTP JSON 发送到 tracing.endpoint
+)
+
+// TracingConfig 是链路追踪的设置。exporter 为空时不记录 span。设置的变化需要重启才能生效
+type TracingConfig struct {
+	Exporter string            `json:"exporter,omitempty"` // "file" 或 "otlp"
+	Path     string            `json:"path,omitempty"`     // file 导出的文件路径
+	Endpoint string            `json:"endpoint,omitempty"` // otlp 导出的 collector 地址，例如 "http://127.0.0.1:4318"
+	Headers  map[string]string `json:"headers,omitempty"`  // otlp 请求附带的 HTTP 头
+}
+
+// AuditConfig 是工具调用审计日志的设置。path 为空时不记录审计日志。
+// 参数和错误信息按 logging.redact 的规则脱敏。设置的变化需要重启才能生效
+type AuditConfig struct {
+	Path        string `json:"path,omitempty"`
+	MaxSizeMB   int    `json:"maxSizeMB,omitempty"`   // 当前文件超过该大小时轮转，0 表示不按大小轮转
+	RotateDaily bool   `json:"rotateDaily,omitempty"` // 日期 (UTC) 变化时轮转
+	MaxBackups  int    `json:"maxBackups,omitempty"`  // 保留的已轮转文件数，0 表示全部保留
+	Arguments   string `json:"arguments,omitempty"`   // "hash" 只记录参数摘要，"redacted" 同时记录脱敏后的参数，默认 "hash"
+}
+
+// ToolsConfig 是工具相关的设置
+type ToolsConfig struct {
+	DefaultTimeout *Duration              `json:"defaultTimeout,omitempty"` // 未单独配置超时的工具的执行时限，"0s" 表示不限制
+	Aliases        map[string]string      `json:"aliases,omitempty"`        // 别名 -> 目标工具的名称或引用
+	Settings       map[string]*ToolConfig `json:"settings,omitempty"`       // 按工具对外名称索引的设置
+}
+
+// ToolConfig 是单个工具的设置，未设置的字段保持默认值
+type ToolConfig struct {
+	Enabled          *bool            `json:"enabled,omitempty"`
+	Timeout          Duration         `json:"timeout,omitempty"`
+	RateLimit        *RateLimitConfig `json:"rateLimit,omitempty"`        // 所有会话共享的限制
+	SessionRateLimit *RateLimitConfig `json:"sessionRateLimit,omitempty"` // 每个会话单独的限制
+	Cache            *CacheConfig     `json:"cache,omitempty"`
+}
+
+// RateLimitConfig 对应 tools.RateLimit
+type RateLimitConfig struct {
+	Rate          float64 `json:"rate"` // 每秒允许的调用数
+	Burst         int     `json:"burst,omitempty"`
+	MaxConcurrent int     `json:"maxConcurrent,omitempty"`
+}
+
+func (c *RateLimitConfig) toRateLimit() tools.RateLimit {
+	if c == nil {
+		return tools.RateLimit{}
+	}
+	return tools.RateLimit{Rate: c.Rate, Burst: c.Burst, MaxConcurrent: c.MaxConcurrent}
+}
+
+// CacheConfig 对应 tools.CacheOptions
+type CacheConfig struct {
+	TTL        Duration `json:"ttl"`
+	MaxEntries int      `json:"maxEntries,omitempty"`
+}
+
+// WeatherConfig 是天气服务的设置
+type WeatherConfig struct {
+	Provider string `json:"provider,omitempty"` // "google_map"、"weatherapi"，为空时按环境变量自动选择
+	APIKey   string `json:"apiKey,omitempty"`   // 为空时从对应的环境变量读取
+}
+
+// Duration 是可以用 "10s"、"1m30s" 这样的字符串表示的时长
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("duration must be a string like \"10s\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Default 返回与未使用配置文件时行为一致的默认配置
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Name:    "mcp-go-weather-server",
+			Version: "0.0.1",
+		},
+		Transports: []TransportConfig{{Type: TransportStdio}},
+		Logging: LoggingConfig{
+			Path: "/tmp/mcp_server_main_debug.log",
+		},
+	}
+}
+
+// Load 读取 JSON 配置文件，展开其中的环境变量，并在默认配置的基础上校验
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+	return Parse(data)
+}
+
+// Parse 解析 JSON 配置。所有字符串值中的 ${VAR} 和 ${VAR:-default} 会被替换为环境变量的值
+func Parse(data []byte) (*Config, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, "failed to parse config file")
+	}
+	expanded, err := json.Marshal(expandEnv(raw))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to expand environment variables in config")
+	}
+
+	cfg := Default()
+	decoder := json.NewDecoder(bytes.NewReader(expanded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// expandEnv 递归地展开 JSON 值中字符串里的环境变量
+func expandEnv(value any) any {
+	switch v := value.(type) {
+	case string:
+		return os.Expand(v, func(key string) string {
+			if name, def, ok := strings.Cut(key, ":-"); ok {
+				if val, set := os.LookupEnv(name); set && val != "" {
+					return val
+				}
+				return def
+			}
+			return os.Getenv(key)
+		})
+	case map[string]any:
+		for k, item := range v {
+			v[k] = expandEnv(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = expandEnv(item)
+		}
+	}
+	return value
+}
+
+// ValidationError 汇总了配置中的所有问题
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate 检查配置是否有效，一次性返回所有问题
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.Name == "" {
+		addf("server.name is required")
+	}
+	if c.Server.PageSize < 0 {
+		addf("server.pageSize must not be negative")
+	}
+	if c.Server.Trace != "" && !server.TraceValue(c.Server.Trace).Valid() {
+		addf("server.trace: unknown trace value '%s'", c.Server.Trace)
+	}
+
+	if len(c.Transports) == 0 {
+		addf("at least one transport is required")
+	}
+	seen := make(map[string]bool)
+	for i, t := range c.Transports {
+		switch t.Type {
+		case TransportStdio:
+		case TransportHTTP:
+			if t.Listen == "" {
+				addf("transports[%d]: listen is required for http transport", i)
+			}
+		default:
+			addf("transports[%d]: unknown type '%s'", i, t.Type)
+		}
+		if seen[t.Type] {
+			addf("transports[%d]: duplicate transport '%s'", i, t.Type)
+		}
+		seen[t.Type] = true
+	}
+
+	if c.Logging.Path == "" {
+		addf("logging.path is required")
+	}
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		addf("logging.level: %v", err)
+	}
+	if !logging.ValidFormat(c.Logging.Format) {
+		addf("logging.format: unknown format '%s'", c.Logging.Format)
+	}
+
+	if c.Tools.DefaultTimeout != nil && *c.Tools.DefaultTimeout < 0 {
+		addf("tools.defaultTimeout must not be negative")
+	}
+	for _, name := range sortedKeys(c.Tools.Settings) {
+		tc := c.Tools.Settings[name]
+		if tc == nil {
+			continue
+		}
+		if tc.Timeout < 0 {
+			addf("tools.settings.%s.timeout must not be negative", name)
+		}
+		if rl := tc.RateLimit; rl != nil && (rl.Rate < 0 || rl.Burst < 0 || rl.MaxConcurrent < 0) {
+			addf("tools.settings.%s.rateLimit values must not be negative", name)
+		}
+		if rl := tc.SessionRateLimit; rl != nil && (rl.Rate < 0 || rl.Burst < 0 || rl.MaxConcurrent < 0) {
+			addf("tools.settings.%s.sessionRateLimit values must not be negative", name)
+		}
+		if tc.Cache != nil && tc.Cache.TTL <= 0 {
+			addf("tools.settings.%s.cache.ttl must be positive", name)
+		}
+	}
+	for _, alias := range sortedKeys(c.Tools.Aliases) {
+		if alias == "" || c.Tools.Aliases[alias] == "" {
+			addf("tools.aliases: alias and target must not be empty")
+		}
+	}
+
+	// 只检查提供方名称，API key 可能在运行时才通过环境变量提供，缺失时由 ApplyWeather 报告
+	switch c.Weather.Provider {
+	case tools.WeatherProviderAuto, tools.WeatherProviderGoogleMap, tools.WeatherProviderWeatherAPI:
+	default:
+		addf("weather: unknown provider '%s'", c.Weather.Provider)
+	}
+
+	names := make(map[string]bool)
+	for i, p := range c.Plugins {
+		if p.Name == "" || p.Command == "" {
+			addf("plugins[%d]: name and command are required", i)
+		}
+		if names["plugin:"+p.Name] {
+			addf("plugins[%d]: duplicate plugin name '%s'", i, p.Name)
+		}
+		names["plugin:"+p.Name] = true
+	}
+	for i, d := range c.Downstream {
+		if d.Name == "" || d.Command == "" {
+			addf("downstream[%d]: name and command are required", i)
+		}
+		if names["downstream:"+d.Name] {
+			addf("downstream[%d]: duplicate server name '%s'", i, d.Name)
+		}
+		names["downstream:"+d.Name] = true
+	}
+
+	if c.Reload.Interval < 0 {
+		addf("reload.interval must not be negative")
+	}
+
+	switch c.Tracing.Exporter {
+	case "":
+	case TraceExporterFile:
+		if c.Tracing.Path == "" {
+			addf("tracing.path is required for file exporter")
+		}
+	case TraceExporterOTLP:
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("tracing.endpoint must be an http or https URL for otlp exporter")
+		}
+	default:
+		addf("tracing.exporter: unknown exporter '%s'", c.Tracing.Exporter)
+	}
+
+	if c.Audit.MaxSizeMB < 0 || c.Audit.MaxBackups < 0 {
+		addf("audit.maxSizeMB and audit.maxBackups must not be negative")
+	}
+	switch c.Audit.Arguments {
+	case "", audit.ArgumentsHash, audit.ArgumentsRedacted:
+	default:
+		addf("audit.arguments: unknown mode '%s'", c.Audit.Arguments)
+	}
+
+	if c.Metrics.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Listen); err != nil {
+			addf("metrics.listen: %v", err)
+		}
+	}
+	if c.Debug.Listen != "" {
+		if host, _, err := net.SplitHostPort(c.Debug.Listen); err != nil {
+			addf("debug.listen: %v", err)
+		} else if !isLoopback(host) {
+			addf("debug.listen: '%s' is not a loopback address", host)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isLoopback 判断监听地址中的主机是否是回环地址，空主机表示监听所有地址
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
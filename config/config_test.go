@@ -0,0 +1,168 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseEnvInterpolation(t *testing.T) {
+	t.Setenv("MCP_TEST_NAME", "from-env")
+	t.Setenv("MCP_TEST_EMPTY", "")
+
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{name: "plain", json: `"${MCP_TEST_NAME}"`, want: "from-env"},
+		{name: "embedded", json: `"srv-${MCP_TEST_NAME}-1"`, want: "srv-from-env-1"},
+		{name: "default unused", json: `"${MCP_TEST_NAME:-fallback}"`, want: "from-env"},
+		{name: "default for unset", json: `"${MCP_TEST_UNSET_VAR:-fallback}"`, want: "fallback"},
+		{name: "default for empty", json: `"${MCP_TEST_EMPTY:-fallback}"`, want: "fallback"},
+		{name: "short form", json: `"$MCP_TEST_NAME"`, want: "from-env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(`{"server": {"name": ` + tt.json + `}}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Name != tt.want {
+				t.Errorf("server.name = %q, want %q", cfg.Server.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNestedInterpolation(t *testing.T) {
+	t.Setenv("MCP_TEST_LOG", "/tmp/test.log")
+	cfg, err := Parse([]byte(`{
+		"logging": {"path": "${MCP_TEST_LOG}"},
+		"tools": {"aliases": {"w": "${MCP_TEST_TARGET:-get_weather}"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Logging.Path != "/tmp/test.log" || cfg.Tools.Aliases["w"] != "get_weather" {
+		t.Errorf("logging.path = %q, aliases = %v", cfg.Logging.Path, cfg.Tools.Aliases)
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	cfg, err := Parse([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := Default()
+	if cfg.Server != def.Server || cfg.Logging.Path != def.Logging.Path || len(cfg.Transports) != 1 {
+		t.Errorf("empty config should keep the defaults, got %+v", cfg)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		problems []string // 错误信息中应当出现的内容，为空表示配置有效
+	}{
+		{name: "valid", json: `{"tools": {"settings": {"get_weather": {"timeout": "5s", "cache": {"ttl": "1m"}}}}}`},
+		{
+			name: "weather key is not required",
+			json: `{"weather": {"provider": "weatherapi"}}`,
+		},
+		{
+			name:     "unknown weather provider",
+			json:     `{"weather": {"provider": "bogus"}}`,
+			problems: []string{"weather: unknown provider 'bogus'"},
+		},
+		{
+			name:     "http transport needs listen",
+			json:     `{"transports": [{"type": "http"}]}`,
+			problems: []string{"listen is required"},
+		},
+		{
+			name:     "unknown and duplicate transports",
+			json:     `{"transports": [{"type": "stdio"}, {"type": "stdio"}, {"type": "ws"}]}`,
+			problems: []string{"duplicate transport 'stdio'", "unknown type 'ws'"},
+		},
+		{
+			name: "all problems are reported together",
+			json: `{"server": {"name": "", "pageSize": -1}, "logging": {"level": "loud"}, "tools": {"settings": {"x": {"timeout": "-1s", "cache": {"ttl": "0s"}}}}}`,
+			problems: []string{
+				"server.name is required",
+				"server.pageSize must not be negative",
+				"logging.level",
+				"tools.settings.x.timeout must not be negative",
+				"tools.settings.x.cache.ttl must be positive",
+			},
+		},
+		{
+			name:     "debug listener must be loopback",
+			json:     `{"debug": {"listen": "0.0.0.0:9465"}}`,
+			problems: []string{"debug.listen: '0.0.0.0' is not a loopback address"},
+		},
+		{name: "debug listener on localhost", json: `{"debug": {"listen": "localhost:9465"}}`},
+		{name: "debug listener on ipv6 loopback", json: `{"debug": {"listen": "[::1]:9465"}}`},
+		{
+			name:     "unknown field",
+			json:     `{"sever": {}}`,
+			problems: []string{"unknown field"},
+		},
+		{
+			name:     "bad duration",
+			json:     `{"tools": {"defaultTimeout": 5}}`,
+			problems: []string{"duration must be a string"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.json))
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, problem := range tt.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("error %q does not mention %q", err, problem)
+				}
+			}
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) && len(validationErr.Problems) < len(tt.problems) {
+				t.Errorf("got %d problems, want at least %d", len(validationErr.Problems), len(tt.problems))
+			}
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{json: `"1m30s"`, want: 90 * time.Second},
+		{json: `"250ms"`, want: 250 * time.Millisecond},
+		{json: `"soon"`, wantErr: true},
+		{json: `10`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var d Duration
+			err := d.UnmarshalJSON([]byte(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && time.Duration(d) != tt.want {
+				t.Errorf("got %v, want %v", time.Duration(d), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+#!/usr/bin/env python3
+"""示例插件：统计文本的字符数、单词数和行数。
+
+协议见 tools/plugin.go：
+  text_stats.py describe          输出工具描述
+  text_stats.py call <tool name>  从 stdin 读取 JSON 参数，输出工具结果
+"""
+import json
+import sys
+
+TOOLS = [
+    {
+        "name": "text_stats",
+        "title": "Text Statistics",
+        "description": "Counts characters, words and lines in a piece of text.",
+        "inputSchema": {
+            "type": "object",
+            "properties": {
+                "text": {"type": "string", "description": "The text to analyze."},
+            },
+            "required": ["text"],
+        },
+        "annotations": {
+            "readOnlyHint": True,
+            "destructiveHint": False,
+            "idempotentHint": True,
+            "openWorldHint": False,
+        },
+    }
+]
+
+
+def text_stats(arguments):
+    text = arguments["text"]
+    stats = {
+        "characters": len(text),
+        "words": len(text.split()),
+        "lines": len(text.splitlines()),
+    }
+    return {
+        "content": [
+            {
+                "type": "text",
+                "text": "characters: {characters}, words: {words}, lines: {lines}".format(**stats),
+            }
+        ],
+    }
+
+
+def main():
+    if len(sys.argv) >= 2 and sys.argv[1] == "describe":
+        json.dump({"tools": TOOLS}, sys.stdout)
+        return 0
+    if len(sys.argv) >= 3 and sys.argv[1] == "call" and sys.argv[2] == "text_stats":
+        json.dump(text_stats(json.load(sys.stdin)), sys.stdout)
+        return 0
+    print("usage: text_stats.py describe | call <tool name>", file=sys.stderr)
+    return 2
+
+
+if __name__ == "__main__":
+    sys.exit(main())
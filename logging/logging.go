@@ -0,0 +1,106 @@
+// Package logging 基于 log/slog 提供服务器使用的结构化日志：
+// 可配置的级别和输出格式、从 context 中携带的请求属性，以及敏感信息脱敏
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// 输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options 是创建日志记录器的选项
+type Options struct {
+	Level             *slog.LevelVar // 为 nil 时使用 info 级别
+	Format            string         // FormatText 或 FormatJSON，为空时使用 FormatText
+	RedactKeys        []string       // 在默认列表之外需要脱敏的属性名
+	RedactQueryParams []string       // 在默认列表之外需要脱敏的 URL 查询参数
+	Extra             []slog.Handler // 同样接收所有脱敏后记录的其他 Handler，按自己的级别过滤
+}
+
+// New 创建写入 w 的日志记录器。所有记录都会经过脱敏，并带上 context 中通过 WithAttrs 附加的属性
+func New(w io.Writer, opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{}
+	if opts.Level != nil {
+		handlerOpts.Level = opts.Level
+	}
+
+	var handler slog.Handler
+	if opts.Format == FormatJSON {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+	if len(opts.Extra) > 0 {
+		handler = Tee(append([]slog.Handler{handler}, opts.Extra...)...)
+	}
+	handler = NewRedactHandler(handler, NewRedactor(opts.RedactKeys, opts.RedactQueryParams))
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel 解析 "debug"、"info"、"warn"、"error" 形式的日志级别，为空时返回 info
+func ParseLevel(level string) (slog.Level, error) {
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return 0, errors.Errorf("unknown log level '%s'", level)
+	}
+	return l, nil
+}
+
+// ValidFormat 判断 format 是否是支持的输出格式
+func ValidFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "", FormatText, FormatJSON:
+		return true
+	}
+	return false
+}
+
+// Discard 返回丢弃所有记录的日志记录器，用于未配置日志的场景
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+type contextAttrsKey struct{}
+
+// WithAttrs 返回附加了日志属性的 context。使用 *Context 系列方法记录日志时，
+// 这些属性会出现在每条记录中，例如请求 ID、方法名和会话 ID
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(contextAttrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, contextAttrsKey{}, merged)
+}
+
+// contextHandler 把 context 中的属性加到每条记录上
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(contextAttrsKey{}).([]slog.Attr); ok {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
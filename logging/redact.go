@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted 是替换敏感值后的占位文本
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys 是默认脱敏的属性名和 JSON 字段名，匹配时不区分大小写
+var DefaultRedactKeys = []string{"apikey", "api_key", "password", "secret", "token", "access_token", "authorization"}
+
+// DefaultRedactQueryParams 是默认脱敏的 URL 查询参数，例如 weatherapi.com 请求中的 key
+var DefaultRedactQueryParams = []string{"key", "apikey", "api_key", "token", "access_token"}
+
+// Redactor 把日志中的敏感属性和 URL 查询参数替换为 Redacted
+type Redactor struct {
+	keys    map[string]bool
+	queryRe *regexp.Regexp
+}
+
+// NewRedactor 创建在默认列表基础上额外脱敏 keys 和 queryParams 的 Redactor
+func NewRedactor(keys, queryParams []string) *Redactor {
+	r := &Redactor{keys: make(map[string]bool)}
+	for _, key := range append(append([]string{}, DefaultRedactKeys...), keys...) {
+		r.keys[strings.ToLower(key)] = true
+	}
+
+	params := append(append([]string{}, DefaultRedactQueryParams...), queryParams...)
+	quoted := make([]string, len(params))
+	for i, param := range params {
+		quoted[i] = regexp.QuoteMeta(param)
+	}
+	r.queryRe = regexp.MustCompile(`(?i)([?&](?:` + strings.Join(quoted, "|") + `)=)[^&#\s"'\\]*`)
+	return r
+}
+
+// String 脱敏字符串中出现的 URL 查询参数
+func (r *Redactor) String(s string) string {
+	if !strings.ContainsAny(s, "?&") {
+		return s
+	}
+	return r.queryRe.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// Attr 脱敏单个日志属性，分组会被递归处理
+func (r *Redactor) Attr(attr slog.Attr) slog.Attr {
+	if r.keys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, r.String(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, a := range group {
+			attrs[i] = r.Attr(a)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(attrs...)}
+	case slog.KindAny:
+		return slog.Any(attr.Key, r.any(value.Any()))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// Value 脱敏 JSON 风格的值 (map[string]any、[]any 和字符串)，返回新的值，不修改 v
+func (r *Redactor) Value(v any) any {
+	return r.value(v)
+}
+
+// any 脱敏任意值：错误信息和 JSON 文本中的查询参数，以及 map 和 JSON 对象中的敏感字段
+func (r *Redactor) any(v any) any {
+	switch v := v.(type) {
+	case error:
+		return r.String(v.Error())
+	case json.RawMessage:
+		var decoded any
+		if err := json.Unmarshal(v, &decoded); err != nil {
+			return r.String(string(v))
+		}
+		redacted, err := json.Marshal(r.value(decoded))
+		if err != nil {
+			return r.String(string(v))
+		}
+		return json.RawMessage(redacted)
+	case map[string]any, []any:
+		return r.value(v)
+	}
+	return v
+}
+
+// value 递归脱敏 JSON 风格的值
+func (r *Redactor) value(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.String(v)
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for key, item := range v {
+			if r.keys[strings.ToLower(key)] {
+				redacted[key] = Redacted
+				continue
+			}
+			redacted[key] = r.value(item)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, item := range v {
+			redacted[i] = r.value(item)
+		}
+		return redacted
+	}
+	return v
+}
+
+// RedactHandler 在记录交给下一个 Handler 之前对消息和属性进行脱敏
+type RedactHandler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+func NewRedactHandler(next slog.Handler, redactor *Redactor) *RedactHandler {
+	return &RedactHandler{next: next, redactor: redactor}
+}
+
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, h.redactor.String(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactor.Attr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactor.Attr(attr)
+	}
+	return &RedactHandler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
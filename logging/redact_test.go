@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactorString(t *testing.T) {
+	r := NewRedactor(nil, []string{"q"})
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "no query", in: "plain message", want: "plain message"},
+		{name: "default param", in: "GET https://api.weatherapi.com/v1/current.json?key=abc123&q=Paris",
+			want: "GET https://api.weatherapi.com/v1/current.json?key=[REDACTED]&q=[REDACTED]"},
+		{name: "case insensitive", in: "https://x/y?API_KEY=secret&lang=en", want: "https://x/y?API_KEY=[REDACTED]&lang=en"},
+		{name: "stops at fragment", in: "https://x/y?token=t#frag", want: "https://x/y?token=[REDACTED]#frag"},
+		{name: "similar name untouched", in: "https://x/y?monkey=1", want: "https://x/y?monkey=1"},
+		{name: "inside quoted JSON", in: `{"url":"https://x/y?key=abc"}`, want: `{"url":"https://x/y?key=[REDACTED]"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.String(tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorValue(t *testing.T) {
+	r := NewRedactor([]string{"location"}, nil)
+	tests := []struct {
+		name string
+		in   any
+		want any
+	}{
+		{name: "sensitive keys", in: map[string]any{"apiKey": "k", "Password": "p", "city": "Paris"},
+			want: map[string]any{"apiKey": Redacted, "Password": Redacted, "city": "Paris"}},
+		{name: "extra key", in: map[string]any{"location": "1,2"}, want: map[string]any{"location": Redacted}},
+		{name: "nested", in: map[string]any{"args": []any{map[string]any{"token": "t"}, "https://x?key=k"}},
+			want: map[string]any{"args": []any{map[string]any{"token": Redacted}, "https://x?key=" + Redacted}}},
+		{name: "scalars untouched", in: 42.0, want: 42.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Value(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	in := map[string]any{"secret": "s"}
+	r.Value(in)
+	if in["secret"] != "s" {
+		t.Error("Value must not modify its argument")
+	}
+}
+
+func TestRedactHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		log   func(*slog.Logger)
+		check map[string]any // 顶层字段 -> 期望值
+	}{
+		{
+			name:  "attribute key",
+			log:   func(l *slog.Logger) { l.Info("call", "authorization", "Bearer x", "tool", "echo") },
+			check: map[string]any{"authorization": Redacted, "tool": "echo"},
+		},
+		{
+			name:  "message and string value",
+			log:   func(l *slog.Logger) { l.Info("GET /?key=abc", "url", "https://x?token=t") },
+			check: map[string]any{"msg": "GET /?key=" + Redacted, "url": "https://x?token=" + Redacted},
+		},
+		{
+			name:  "error value",
+			log:   func(l *slog.Logger) { l.Error("failed", "error", errors.New(`Get "https://x?key=abc": timeout`)) },
+			check: map[string]any{"error": `Get "https://x?key=` + Redacted + `": timeout`},
+		},
+		{
+			name:  "WithAttrs",
+			log:   func(l *slog.Logger) { l.With("password", "p").Info("hello") },
+			check: map[string]any{"password": Redacted},
+		},
+		{
+			name:  "group",
+			log:   func(l *slog.Logger) { l.Info("hello", slog.Group("req", "secret", "s", "id", 1)) },
+			check: map[string]any{"req": map[string]any{"secret": Redacted, "id": 1.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			handler := NewRedactHandler(slog.NewJSONHandler(&buf, nil), NewRedactor(nil, nil))
+			tt.log(slog.New(handler))
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("invalid log output %q: %v", buf.String(), err)
+			}
+			for key, want := range tt.check {
+				if !reflect.DeepEqual(record[key], want) {
+					t.Errorf("%s = %#v, want %#v", key, record[key], want)
+				}
+			}
+			if strings.Contains(buf.String(), "abc") {
+				t.Errorf("secret leaked: %s", buf.String())
+			}
+		})
+	}
+}
@@ -1,56 +1,50 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"fmt"
-	"log"
 	"os"
-
-	"github.com/n8sPxD/mcp-server-demo/server"
+	"strings"
 )
 
-func main() {
-	fmt.Fprintln(os.Stderr, "DEBUG: MCP server started")
-
-	// 日志写入文件
-	file, err := os.OpenFile("/tmp/mcp_server_main_debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalf("Failed to open log file for main: %s", err)
-	}
-
-	logger := log.New(file, "[MCP Main] ", log.LstdFlags)    // 日志写入文件
-	fmt.Fprintln(file, "DEBUG: MCP server started in main.") // 也写入文件
+// version 是程序版本，可以在构建时通过 -ldflags "-X main.version=..." 覆盖
+var version = "0.0.1"
 
-	stdinReader := bufio.NewReader(os.Stdin)
-	stdoutWriter := bufio.NewWriter(os.Stdout)
+const usage = `Usage: mcp-server-demo <command> [flags]
 
-	server := server.NewMCPServer(stdinReader, stdoutWriter, file)
-	logger.Println("MCP server instance created. Waiting for messages...")
+Commands:
+  serve                      Start the MCP server (default when no command is given)
+  tools list                 Print the registered tool schemas
+  tools call <name> [flags]  Invoke a tool directly without an MCP host
+  replay <recording.jsonl>   Replay a recorded session and diff the responses
+  audit query [flags]        Search the tool call audit log
+  version                    Print version information
 
-	go func() {
-		scanner := bufio.NewScanner(stdinReader)
-		logger.Println("Scanner created. Using default line splitting. Entering scan loop...") // 更新日志
+Run "mcp-server-demo <command> -h" to see the flags of a command.
+`
 
-		for scanner.Scan() {
-			messageBytes := scanner.Bytes()
-			if len(bytes.TrimSpace(messageBytes)) == 0 {
-				logger.Println("Received an empty line, skipping.")
-				continue
-			}
-
-			server.ProcessMessage(messageBytes)
-		}
+func main() {
+	args := os.Args[1:]
 
-		if err := scanner.Err(); err != nil {
-			logger.Printf("Error reading from stdin: %v", err)
-		}
-		logger.Println("Stdin scanner finished.")
-		// 如果输入结束，也应该关闭服务器
-		close(server.ShutdownSignal)
-	}()
+	// 不带子命令时默认启动服务器，兼容直接启动二进制的 MCP 宿主
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		os.Exit(runServe(args))
+	}
 
-	// 等待服务器关闭信号
-	<-server.ShutdownSignal
-	logger.Println("MCP server shut down gracefully.")
+	switch args[0] {
+	case "serve":
+		os.Exit(runServe(args[1:]))
+	case "tools":
+		os.Exit(runTools(args[1:]))
+	case "replay":
+		os.Exit(runReplay(args[1:]))
+	case "audit":
+		os.Exit(runAudit(args[1:]))
+	case "version":
+		os.Exit(runVersion(args[1:]))
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		os.Exit(2)
+	}
 }
@@ -0,0 +1,387 @@
+// Package metrics 实现 Prometheus 文本格式的计数器、仪表盘和直方图，
+// 以及暴露这些指标的 /metrics HTTP 处理器
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 指标类型
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefBuckets 是直方图的默认分桶 (秒)，适合请求和工具调用的耗时
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// collector 是一组同名、不同标签取值的指标
+type collector interface {
+	name() string
+	write(w *bytes.Buffer)
+}
+
+// Registry 保存所有已注册的指标
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default 是包级别的 New* 函数注册指标的默认注册表
+var Default = NewRegistry()
+
+// register 注册一个指标，同名指标重复注册时 panic
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[c.name()]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", c.name()))
+	}
+	r.collectors[c.name()] = c
+}
+
+// WriteText 以 Prometheus 文本格式输出所有指标，按指标名排序
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	collectors := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+
+	var buf bytes.Buffer
+	for _, c := range collectors {
+		c.write(&buf)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Handler 返回以文本格式输出所有指标的 HTTP 处理器
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// ListenAndServe 在 addr 上提供 /metrics 端点，直到出错才返回
+func (r *Registry) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+	return http.ListenAndServe(addr, mux)
+}
+
+// vec 是按标签取值索引的一组指标，是所有指标类型的公共部分
+type vec[T any] struct {
+	metricName string
+	help       string
+	typ        string
+	labels     []string
+	newMetric  func() *T
+
+	mu      sync.Mutex
+	metrics map[string]*T
+	values  map[string][]string // 索引 -> 标签取值
+}
+
+func newVec[T any](name, help, typ string, labels []string, newMetric func() *T) *vec[T] {
+	return &vec[T]{
+		metricName: name,
+		help:       help,
+		typ:        typ,
+		labels:     labels,
+		newMetric:  newMetric,
+		metrics:    make(map[string]*T),
+		values:     make(map[string][]string),
+	}
+}
+
+func (v *vec[T]) name() string {
+	return v.metricName
+}
+
+// with 返回标签取值对应的指标，不存在时创建。标签取值的数量必须与标签名一致
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	m, ok := v.metrics[key]
+	if !ok {
+		m = v.newMetric()
+		v.metrics[key] = m
+		v.values[key] = append([]string(nil), values...)
+	}
+	return m
+}
+
+// each 按标签取值排序遍历所有指标
+func (v *vec[T]) each(fn func(labels string, m *T)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.metrics))
+	for key := range v.metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	type item struct {
+		labels string
+		metric *T
+	}
+	items := make([]item, len(keys))
+	for i, key := range keys {
+		items[i] = item{labels: formatLabels(v.labels, v.values[key]), metric: v.metrics[key]}
+	}
+	v.mu.Unlock()
+
+	for _, it := range items {
+		fn(it.labels, it.metric)
+	}
+}
+
+func (v *vec[T]) writeHeader(w *bytes.Buffer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.metricName, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.metricName, v.typ)
+}
+
+// Counter 是只增不减的计数器
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add 增加计数，delta 必须非负
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.mu.Lock()
+	c.value += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// CounterVec 是按标签区分的一组计数器
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// NewCounterVec 创建计数器并注册到 Default
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, typeCounter, labels, func() *Counter { return &Counter{} })}
+	Default.register(c)
+	return c
+}
+
+// WithLabelValues 返回标签取值对应的计数器
+func (c *CounterVec) WithLabelValues(values ...string) *Counter {
+	return c.with(values)
+}
+
+func (c *CounterVec) write(w *bytes.Buffer) {
+	c.writeHeader(w)
+	c.each(func(labels string, m *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, labels, formatFloat(m.get()))
+	})
+}
+
+// Gauge 是可增可减的仪表盘
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) get() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+// GaugeVec 是按标签区分的一组仪表盘
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+// NewGaugeVec 创建仪表盘并注册到 Default
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, typeGauge, labels, func() *Gauge { return &Gauge{} })}
+	Default.register(g)
+	return g
+}
+
+// NewGauge 创建没有标签的仪表盘并注册到 Default
+func NewGauge(name, help string) *Gauge {
+	return NewGaugeVec(name, help).WithLabelValues()
+}
+
+// WithLabelValues 返回标签取值对应的仪表盘
+func (g *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return g.with(values)
+}
+
+func (g *GaugeVec) write(w *bytes.Buffer) {
+	g.writeHeader(w)
+	g.each(func(labels string, m *Gauge) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, labels, formatFloat(m.get()))
+	})
+}
+
+// Histogram 统计观测值在各个分桶中的分布
+type Histogram struct {
+	buckets []float64 // 各分桶的上界，升序
+
+	mu     sync.Mutex
+	counts []uint64 // counts[i] 是落入 (buckets[i-1], buckets[i]] 的观测数，最后一项对应 +Inf
+	sum    float64
+	count  uint64
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.buckets, value)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += value
+	h.count++
+	h.mu.Unlock()
+}
+
+// HistogramVec 是按标签区分的一组直方图
+type HistogramVec struct {
+	*vec[Histogram]
+	buckets []float64
+}
+
+// NewHistogramVec 创建直方图并注册到 Default，buckets 为 nil 时使用 DefBuckets
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{
+		vec: newVec(name, help, typeHistogram, labels, func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets)+1)}
+		}),
+		buckets: buckets,
+	}
+	Default.register(h)
+	return h
+}
+
+// WithLabelValues 返回标签取值对应的直方图
+func (h *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return h.with(values)
+}
+
+func (h *HistogramVec) write(w *bytes.Buffer) {
+	h.writeHeader(w)
+	h.each(func(labels string, m *Histogram) {
+		m.mu.Lock()
+		counts := append([]uint64(nil), m.counts...)
+		sum, count := m.sum, m.count
+		m.mu.Unlock()
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, withLabel(labels, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, withLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labels, formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labels, count)
+	})
+}
+
+// formatLabels 把标签格式化为 {a="1",b="2"}，没有标签时返回空字符串
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel 在已格式化的标签中追加一个标签
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + escapeLabel(value) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
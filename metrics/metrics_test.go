@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(r *Registry)
+		want  string
+	}{
+		{
+			name: "counter with labels",
+			setup: func(r *Registry) {
+				c := &CounterVec{newVec("requests_total", "Requests.", typeCounter, []string{"method", "status"}, func() *Counter { return &Counter{} })}
+				r.register(c)
+				c.WithLabelValues("tools/list", "ok").Inc()
+				c.WithLabelValues("initialize", "ok").Add(2)
+			},
+			want: `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{method="initialize",status="ok"} 2
+requests_total{method="tools/list",status="ok"} 1
+`,
+		},
+		{
+			name: "gauge without labels",
+			setup: func(r *Registry) {
+				g := &GaugeVec{newVec("in_flight", "In flight.", typeGauge, nil, func() *Gauge { return &Gauge{} })}
+				r.register(g)
+				gauge := g.WithLabelValues()
+				gauge.Inc()
+				gauge.Inc()
+				gauge.Dec()
+				gauge.Add(0.5)
+			},
+			want: `# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 1.5
+`,
+		},
+		{
+			name: "histogram buckets are cumulative and inclusive",
+			setup: func(r *Registry) {
+				buckets := []float64{0.1, 1}
+				h := &HistogramVec{
+					vec: newVec("duration_seconds", "Duration.", typeHistogram, []string{"tool"}, func() *Histogram {
+						return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets)+1)}
+					}),
+					buckets: buckets,
+				}
+				r.register(h)
+				for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+					h.WithLabelValues("echo").Observe(v)
+				}
+			},
+			want: `# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{tool="echo",le="0.1"} 2
+duration_seconds_bucket{tool="echo",le="1"} 3
+duration_seconds_bucket{tool="echo",le="+Inf"} 4
+duration_seconds_sum{tool="echo"} 3.65
+duration_seconds_count{tool="echo"} 4
+`,
+		},
+		{
+			name: "escaping",
+			setup: func(r *Registry) {
+				c := &CounterVec{newVec("errors_total", "Errors with \\ and\nnewline.", typeCounter, []string{"msg"}, func() *Counter { return &Counter{} })}
+				r.register(c)
+				c.WithLabelValues("say \"hi\"\n").Inc()
+			},
+			want: `# HELP errors_total Errors with \\ and\nnewline.
+# TYPE errors_total counter
+errors_total{msg="say \"hi\"\n"} 1
+`,
+		},
+		{
+			name: "metrics are sorted by name",
+			setup: func(r *Registry) {
+				for _, name := range []string{"b_total", "a_total"} {
+					c := &CounterVec{newVec(name, "X.", typeCounter, nil, func() *Counter { return &Counter{} })}
+					r.register(c)
+					c.WithLabelValues().Inc()
+				}
+			},
+			want: `# HELP a_total X.
+# TYPE a_total counter
+a_total 1
+# HELP b_total X.
+# TYPE b_total counter
+b_total 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			tt.setup(r)
+			var out strings.Builder
+			if err := r.WriteText(&out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	c := &CounterVec{newVec("hits_total", "Hits.", typeCounter, nil, func() *Counter { return &Counter{} })}
+	r.register(c)
+	c.WithLabelValues().Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "hits_total 1\n") {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	r := NewRegistry()
+	newCounter := func() *CounterVec {
+		return &CounterVec{newVec("dup_total", "Dup.", typeCounter, nil, func() *Counter { return &Counter{} })}
+	}
+	r.register(newCounter())
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate metric should panic")
+		}
+	}()
+	r.register(newCounter())
+}
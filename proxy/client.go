@@ -0,0 +1,364 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/server"
+	"github.com/n8sPxD/mcp-server-demo/tools"
+	"github.com/pkg/errors"
+)
+
+// ClientProtocolVersion 是连接下游服务器时请求的协议版本
+const ClientProtocolVersion = "2024-11-05"
+
+// maxMessageSize 是从下游读取的单条消息的最大字节数
+const maxMessageSize = 16 * 1024 * 1024
+
+// closeTimeout 是关闭下游进程时等待其自行退出的时间
+const closeTimeout = 3 * time.Second
+
+// ErrClientClosed 表示下游连接已经关闭
+var ErrClientClosed = errors.New("downstream connection closed")
+
+// Client 是一个通过 stdio 连接下游 MCP 服务器的最小客户端
+type Client struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	logger *slog.Logger
+
+	writeMu sync.Mutex
+
+	mu             sync.Mutex
+	nextID         int64
+	pending        map[int64]chan *server.ResponseMessage
+	onNotification func(notif server.NotificationMessage)
+
+	closed   chan struct{}
+	closeErr error
+
+	ServerInfo server.ServerInfo
+}
+
+// StartClient 启动下游服务器进程并完成 initialize 握手
+func StartClient(ctx context.Context, config ServerConfig, logger *slog.Logger) (*Client, error) {
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Dir = config.Dir
+	if len(config.Env) > 0 {
+		cmd.Env = append(os.Environ(), config.Env...)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open downstream stdin")
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open downstream stdout")
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open downstream stderr")
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "failed to start downstream server '%s'", config.Name)
+	}
+
+	c := &Client{
+		name:    config.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		logger:  logger.With("downstream", config.Name),
+		pending: make(map[int64]chan *server.ResponseMessage),
+		closed:  make(chan struct{}),
+	}
+	go c.readLoop(stdout)
+	go c.logStderr(stderr)
+
+	// 握手有时限，避免一个启动后不应答的下游卡住挂载 (以及持有注册表锁的配置重新加载)
+	initCtx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+	if err := c.initialize(initCtx); err != nil {
+		c.kill()
+		return nil, err
+	}
+	return c, nil
+}
+
+// OnNotification 设置处理下游通知的回调
+func (c *Client) OnNotification(fn func(notif server.NotificationMessage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onNotification = fn
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	protocolVersion := ClientProtocolVersion
+	params := server.InitializeParams{
+		ProtocolVersion: &protocolVersion,
+		ClientInfo:      &server.ClientInfo{Name: "mcp-go-weather-server-proxy", Version: "0.0.1"},
+		Capabilities:    json.RawMessage(`{}`),
+	}
+
+	var result server.InitializeResult
+	if err := c.Call(ctx, "initialize", params, &result); err != nil {
+		return errors.Wrapf(err, "failed to initialize downstream server '%s'", c.name)
+	}
+	c.ServerInfo = result.ServerInfo
+
+	return c.Notify("notifications/initialized", nil)
+}
+
+// ListTools 获取下游服务器的全部工具，会自动跟随分页游标
+func (c *Client) ListTools(ctx context.Context) ([]tools.ToolDefinition, error) {
+	var all []tools.ToolDefinition
+	cursor := ""
+	for {
+		var result server.ListToolsResult
+		params := server.ListToolsParams{PaginatedParams: server.PaginatedParams{Cursor: cursor}}
+		if err := c.Call(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		all = append(all, result.Tools...)
+		if result.NextCursor == "" {
+			return all, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool 调用下游服务器的工具
+func (c *Client) CallTool(ctx context.Context, name string, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+	var result tools.ExecuteToolResult
+	params := tools.ExecuteToolParams{ToolName: name, Inputs: inputs}
+	if err := c.Call(ctx, "tools/call", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Call 发送一个请求并等待响应，result 为 nil 时忽略响应结果
+func (c *Client) Call(ctx context.Context, method string, params any, result any) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *server.ResponseMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	rawID := json.RawMessage(strconv.FormatInt(id, 10))
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal request params")
+	}
+	req := server.RequestMessage{
+		BaseMessage: server.BaseMessage{JSONRPC: server.JSONRPCVersion, ID: &rawID},
+		Method:      method,
+		Params:      paramsBytes,
+	}
+	if err := c.write(req); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return fmt.Errorf("downstream '%s' returned error %d: %s", c.name, resp.Error.Code, resp.Error.Message)
+		}
+		if result == nil {
+			return nil
+		}
+		return errors.Wrapf(json.Unmarshal(resp.Result, result), "failed to decode %s result from downstream '%s'", method, c.name)
+	case <-c.closed:
+		return ErrClientClosed
+	case <-ctx.Done():
+		// 通知下游取消该请求
+		c.Notify("notifications/cancelled", map[string]any{"requestId": id, "reason": ctx.Err().Error()})
+		return ctx.Err()
+	}
+}
+
+// Notify 向下游发送一个通知
+func (c *Client) Notify(method string, params any) error {
+	notif := server.NotificationMessage{JSONRPC: server.JSONRPCVersion, Method: method}
+	if params != nil {
+		paramsBytes, err := json.Marshal(params)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal notification params")
+		}
+		notif.Params = paramsBytes
+	}
+	return c.write(notif)
+}
+
+func (c *Client) write(message any) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal message")
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	select {
+	case <-c.closed:
+		return ErrClientClosed
+	default:
+	}
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		return errors.Wrapf(err, "failed to write to downstream '%s'", c.name)
+	}
+	return nil
+}
+
+// readLoop 读取下游的消息，把响应分发给等待中的请求，把通知交给回调
+func (c *Client) readLoop(stdout io.Reader) {
+	defer c.markClosed()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var probe struct {
+			ID     *json.RawMessage `json:"id"`
+			Method string           `json:"method"`
+		}
+		if err := json.Unmarshal(line, &probe); err != nil {
+			c.logger.Warn("Downstream sent invalid JSON", "error", err)
+			continue
+		}
+
+		switch {
+		case probe.ID != nil && probe.Method == "":
+			var resp server.ResponseMessage
+			if err := json.Unmarshal(line, &resp); err != nil {
+				c.logger.Warn("Downstream sent an invalid response", "error", err)
+				continue
+			}
+			c.dispatchResponse(&resp)
+		case probe.ID != nil:
+			// 下游发起的请求，只支持 ping
+			c.handleRequest(line)
+		default:
+			var notif server.NotificationMessage
+			if err := json.Unmarshal(line, &notif); err != nil {
+				continue
+			}
+			c.mu.Lock()
+			fn := c.onNotification
+			c.mu.Unlock()
+			if fn != nil {
+				fn(notif)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		c.logger.Error("Error reading from downstream", "error", err)
+	}
+}
+
+func (c *Client) dispatchResponse(resp *server.ResponseMessage) {
+	id, err := strconv.ParseInt(string(*resp.ID), 10, 64)
+	if err != nil {
+		c.logger.Warn("Downstream sent a response with unexpected id", "id", string(*resp.ID))
+		return
+	}
+
+	c.mu.Lock()
+	ch, ok := c.pending[id]
+	c.mu.Unlock()
+	if ok {
+		ch <- resp
+	}
+}
+
+func (c *Client) handleRequest(line []byte) {
+	var req server.RequestMessage
+	if err := json.Unmarshal(line, &req); err != nil {
+		return
+	}
+
+	resp := server.ResponseMessage{BaseMessage: server.BaseMessage{JSONRPC: server.JSONRPCVersion, ID: req.ID}}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage(`{}`)
+	} else {
+		resp.Error = &server.ErrorObject{Code: server.MethodNotFoundCode, Message: "Method not found: " + req.Method}
+	}
+	if err := c.write(resp); err != nil {
+		c.logger.Warn("Failed to answer downstream request", "error", err)
+	}
+}
+
+func (c *Client) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		c.logger.Info("Downstream stderr", "line", scanner.Text())
+	}
+}
+
+func (c *Client) markClosed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+}
+
+// Closed 返回一个在下游连接关闭时关闭的 channel
+func (c *Client) Closed() <-chan struct{} {
+	return c.closed
+}
+
+// kill 立即结束下游进程，用于握手失败时不再等待进程自行退出
+func (c *Client) kill() {
+	c.writeMu.Lock()
+	c.stdin.Close()
+	c.writeMu.Unlock()
+
+	c.cmd.Process.Kill()
+	c.closeErr = c.cmd.Wait()
+	c.markClosed()
+}
+
+// Close 关闭下游的 stdin 并等待进程退出，超时后强制结束进程
+func (c *Client) Close() error {
+	c.writeMu.Lock()
+	c.stdin.Close()
+	c.writeMu.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- c.cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		c.closeErr = err
+	case <-time.After(closeTimeout):
+		c.cmd.Process.Kill()
+		c.closeErr = <-done
+	}
+	c.markClosed()
+	return c.closeErr
+}
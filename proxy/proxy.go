@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/server"
+	"github.com/n8sPxD/mcp-server-demo/tools"
+	"github.com/pkg/errors"
+)
+
+// syncTimeout 是从下游拉取工具列表的时限
+const syncTimeout = 30 * time.Second
+
+// ServerConfig 描述一个要挂载的下游 MCP 服务器
+type ServerConfig struct {
+	Name    string   `json:"name"`
+	Prefix  string   `json:"prefix,omitempty"` // 工具名前缀，默认与 Name 相同
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"` // KEY=VALUE，追加到当前进程的环境变量之后
+	Dir     string   `json:"dir,omitempty"`
+}
+
+// Mount 把一个下游服务器的工具注册到本地注册表，前缀作为命名空间，工具以 "<prefix>.<name>" 暴露，
+// 调用时转发给下游。下游发送 tools/list_changed 时会重新同步
+type Mount struct {
+	config   ServerConfig
+	client   *Client
+	registry *tools.Registry
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	names []string // 当前挂载的下游工具的原始名称
+}
+
+// MountServer 启动下游服务器并挂载它的工具
+func MountServer(ctx context.Context, registry *tools.Registry, config ServerConfig, logger *slog.Logger) (*Mount, error) {
+	if config.Prefix == "" {
+		config.Prefix = config.Name
+	}
+
+	client, err := StartClient(ctx, config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mount{config: config, client: client, registry: registry, logger: logger}
+	if err := m.Sync(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	client.OnNotification(m.handleNotification)
+	go func() {
+		// 下游进程意外退出时移除它的工具
+		<-client.Closed()
+		m.unregisterAll()
+	}()
+	return m, nil
+}
+
+// Tools 返回当前挂载的工具在本地暴露的名称
+func (m *Mount) Tools() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	source := m.source()
+	names := make([]string, 0, len(m.names))
+	for _, name := range m.names {
+		names = append(names, source.QualifiedName(name))
+	}
+	return names
+}
+
+// Sync 从下游拉取工具列表，并更新本地注册表
+func (m *Mount) Sync(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	defs, err := m.client.ListTools(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list tools of downstream '%s'", m.config.Name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	source := m.source()
+	current := make(map[string]bool, len(defs))
+	names := make([]string, 0, len(defs))
+	for _, def := range defs {
+		remoteName := def.Name
+		current[remoteName] = true
+		names = append(names, remoteName)
+
+		m.registry.RegisterFrom(source, def, func(ctx context.Context, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+			return m.client.CallTool(ctx, remoteName, inputs)
+		})
+	}
+	for _, name := range m.names {
+		if !current[name] {
+			m.registry.UnregisterFrom(source, name)
+		}
+	}
+	m.names = names
+	return nil
+}
+
+// source 返回下游工具在注册表中的来源，前缀作为命名空间
+func (m *Mount) source() tools.Source {
+	return tools.Source{Kind: tools.SourceProxy, Name: m.config.Name, Namespace: m.config.Prefix}
+}
+
+func (m *Mount) handleNotification(notif server.NotificationMessage) {
+	switch notif.Method {
+	case "notifications/tools/list_changed":
+		// 在独立的 goroutine 中同步，避免阻塞下游消息的读取
+		go func() {
+			if err := m.Sync(context.Background()); err != nil {
+				m.logger.Warn("Failed to resync downstream", "downstream", m.config.Name, "error", err)
+			}
+		}()
+	default:
+		m.logger.Debug("Ignoring downstream notification", "downstream", m.config.Name, "method", notif.Method)
+	}
+}
+
+func (m *Mount) unregisterAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.registry.UnregisterSource(m.source())
+	m.names = nil
+}
+
+// Close 移除挂载的工具并关闭下游服务器
+func (m *Mount) Close() error {
+	m.unregisterAll()
+	return m.client.Close()
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/tools"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// buildDemoServer 编译 go-mcp-demo 作为下游服务器
+func buildDemoServer(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds go-mcp-demo")
+	}
+	bin := filepath.Join(t.TempDir(), "go-mcp-demo")
+	out, err := exec.Command("go", "build", "-o", bin, "../go-mcp-demo").CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to build go-mcp-demo: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func TestMountServer(t *testing.T) {
+	bin := buildDemoServer(t)
+	registry := tools.NewRegistry()
+
+	mount, err := MountServer(context.Background(), registry, ServerConfig{Name: "demo", Command: bin}, discardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mount.Tools(); len(got) != 1 || got[0] != "demo.hello_world" {
+		t.Fatalf("Tools() = %v, want [demo.hello_world]", got)
+	}
+	if mount.client.ServerInfo.Name == "" {
+		t.Error("ServerInfo was not filled in by initialize")
+	}
+
+	tests := []struct {
+		name    string
+		inputs  map[string]any
+		want    string
+		wantErr bool
+	}{
+		{name: "forwarded", inputs: map[string]any{"name": "gopher"}, want: "Hello, gopher!"},
+		{name: "downstream error", inputs: map[string]any{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := registry.Call(context.Background(), "demo.hello_world", tt.inputs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Call() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			text, ok := result.Content[0].(*tools.TextContent)
+			if !ok || text.Text != tt.want {
+				t.Errorf("content = %+v, want %q", result.Content[0], tt.want)
+			}
+		})
+	}
+
+	if err := mount.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	if _, ok := registry.GetTool("demo.hello_world"); ok {
+		t.Error("tools are still registered after Close")
+	}
+}
+
+func TestMountServerExited(t *testing.T) {
+	bin := buildDemoServer(t)
+	registry := tools.NewRegistry()
+
+	mount, err := MountServer(context.Background(), registry, ServerConfig{Name: "demo", Command: bin}, discardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mount.client.cmd.Process.Kill()
+
+	select {
+	case <-mount.client.Closed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("client was not closed after the downstream exited")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := registry.GetTool("demo.hello_world"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("tools of an exited downstream are still registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mount.Close()
+}
+
+func TestStartClientUnresponsive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := StartClient(ctx, ServerConfig{Name: "silent", Command: "sleep", Args: []string{"1000"}}, discardLogger())
+	if err == nil {
+		t.Fatal("StartClient succeeded against a server that never answers")
+	}
+	// 超时后立即结束进程，不等待 closeTimeout
+	if elapsed := time.Since(start); elapsed > closeTimeout {
+		t.Errorf("StartClient returned after %v", elapsed)
+	}
+}
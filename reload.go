@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/config"
+)
+
+// watchConfig 定期检查配置文件、插件目录和插件可执行文件，发生变化时重新加载配置，
+// 直到 stop 被关闭或新配置关闭了热加载。adjust 用于在新配置上重新应用命令行参数的覆盖
+func (a *app) watchConfig(path string, adjust func(*config.Config), stop <-chan struct{}) {
+	a.logger.Info("Watching config for changes", "path", path)
+	last := a.configStamp(path)
+
+	for {
+		a.mu.Lock()
+		interval := time.Duration(a.cfg.Reload.Interval)
+		enabled := a.cfg.Reload.Enabled
+		a.mu.Unlock()
+		if !enabled {
+			a.logger.Info("Config reload disabled, stopped watching config")
+			return
+		}
+		if interval <= 0 {
+			interval = config.DefaultReloadInterval
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+
+		current := a.configStamp(path)
+		if current == last {
+			continue
+		}
+		last = current
+		a.reload(path, adjust)
+	}
+}
+
+// reload 重新读取配置文件并应用。无效的配置会被拒绝，当前配置和会话不受影响
+func (a *app) reload(path string, adjust func(*config.Config)) {
+	cfg, err := config.Load(path)
+	if err == nil {
+		adjust(cfg)
+		err = cfg.Validate()
+	}
+	a.mu.Lock()
+	prev := a.cfg
+	a.mu.Unlock()
+	// Validate 不检查 API key，切换天气服务前先确认新的服务可用，避免只应用了一部分配置
+	if err == nil && !reflect.DeepEqual(cfg.Weather, prev.Weather) {
+		_, err = cfg.WeatherGetter()
+	}
+	if err != nil {
+		a.logger.Error("Rejected config reload, keeping the current config", "path", path, "error", err)
+		fmt.Fprintf(os.Stderr, "Rejected config reload, keeping the current config: %v\n", err)
+		return
+	}
+
+	if !reflect.DeepEqual(cfg.Transports, prev.Transports) {
+		a.logger.Warn("Config reload: transport changes take effect after a restart")
+	}
+	if cfg.Metrics != prev.Metrics {
+		a.logger.Warn("Config reload: metrics changes take effect after a restart")
+	}
+	if !reflect.DeepEqual(cfg.Tracing, prev.Tracing) {
+		a.logger.Warn("Config reload: tracing changes take effect after a restart")
+	}
+	if cfg.Audit != prev.Audit {
+		a.logger.Warn("Config reload: audit changes take effect after a restart")
+	}
+	// 日志级别可以立即生效，其余日志设置需要重启
+	if err := cfg.ApplyLogLevel(a.logLevel); err != nil {
+		a.logger.Error("Failed to apply log level", "error", err)
+	}
+	if cfg.Logging.Path != prev.Logging.Path || cfg.Logging.Format != prev.Logging.Format || !reflect.DeepEqual(cfg.Logging.Redact, prev.Logging.Redact) {
+		a.logger.Warn("Config reload: logging changes other than the level take effect after a restart")
+	}
+
+	a.apply(cfg)
+	a.logger.Info("Reloaded config", "path", path)
+}
+
+// configStamp 汇总配置文件、插件目录中的文件以及已声明插件的可执行文件的修改时间和大小，
+// 任何一项变化都会使结果不同
+func (a *app) configStamp(path string) string {
+	a.mu.Lock()
+	cfg := a.cfg
+	a.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString(fileStamp(path))
+	for _, plugin := range cfg.Plugins {
+		b.WriteString("|" + fileStamp(plugin.Command))
+	}
+	if cfg.PluginDir != "" {
+		entries, _ := os.ReadDir(cfg.PluginDir)
+		for _, entry := range entries {
+			b.WriteString("|" + entry.Name() + ":" + fileStamp(filepath.Join(cfg.PluginDir, entry.Name())))
+		}
+	}
+	return b.String()
+}
+
+// fileStamp 返回文件的修改时间、大小和权限，文件不存在时返回空字符串
+func fileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d/%s", info.ModTime().UnixNano(), info.Size(), info.Mode())
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/config"
+	"github.com/n8sPxD/mcp-server-demo/tools"
+)
+
+// testConfig 返回写入临时日志文件的配置，settings 是 tools.settings 的 JSON，
+// weather 是 weather 的 JSON
+func testConfig(logPath, settings, weather string) string {
+	return fmt.Sprintf(`{
+		"logging": {"path": %q, "level": "debug"},
+		"reload": {"enabled": true, "interval": "10ms"},
+		"weather": %s,
+		"tools": {"settings": %s}
+	}`, logPath, weather, settings)
+}
+
+// newTestApp 用 path 处的配置文件创建 app
+func newTestApp(t *testing.T, path string) *app {
+	t.Helper()
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := newApp(cfg, strings.NewReader(""), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		a.Close()
+		tools.SetWeatherGetter(nil)
+	})
+	return a
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func noAdjust(*config.Config) {}
+
+func TestReload(t *testing.T) {
+	const (
+		enabled  = `{"caculator": {"enabled": true}}`
+		disabled = `{"caculator": {"enabled": false}}`
+		auto     = `{}`
+	)
+	t.Setenv("WEATHER_API_KEY", "")
+	t.Setenv("GOOGLE_MAP_API_KEY", "")
+
+	tests := []struct {
+		name         string
+		settings     string
+		weather      string
+		raw          string // 非空时直接写入该内容
+		wantApplied  bool
+		wantProvider string
+	}{
+		{name: "tool disabled", settings: disabled, weather: auto, wantApplied: true, wantProvider: ""},
+		{name: "weather provider with key", settings: disabled, weather: `{"provider": "weatherapi", "apiKey": "k"}`, wantApplied: true, wantProvider: "weatherapi"},
+		{name: "invalid JSON", raw: `{"tools": `},
+		{name: "invalid value", settings: `{"caculator": {"enabled": false, "timeout": "-1s"}}`, weather: auto},
+		{name: "unknown weather provider", settings: disabled, weather: `{"provider": "sunny"}`},
+		{name: "weather provider without key", settings: disabled, weather: `{"provider": "weatherapi"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config.json")
+			logPath := filepath.Join(dir, "server.log")
+			writeConfig(t, path, testConfig(logPath, enabled, auto))
+			a := newTestApp(t, path)
+			before := a.cfg
+
+			content := tt.raw
+			if content == "" {
+				content = testConfig(logPath, tt.settings, tt.weather)
+			}
+			writeConfig(t, path, content)
+			a.reload(path, noAdjust)
+
+			_, toolEnabled := a.server.Registry().GetTool("caculator")
+			if tt.wantApplied {
+				if a.cfg == before {
+					t.Fatal("valid config was not applied")
+				}
+				if toolEnabled {
+					t.Error("caculator is still enabled")
+				}
+				if a.cfg.Weather.Provider != tt.wantProvider {
+					t.Errorf("weather provider = %q, want %q", a.cfg.Weather.Provider, tt.wantProvider)
+				}
+				return
+			}
+			if a.cfg != before {
+				t.Errorf("rejected config replaced the running config: %+v", a.cfg)
+			}
+			if !toolEnabled {
+				t.Error("rejected config changed the tool settings")
+			}
+			if log, _ := os.ReadFile(logPath); !strings.Contains(string(log), "Rejected config reload") {
+				t.Error("the rejection was not logged")
+			}
+		})
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	logPath := filepath.Join(dir, "server.log")
+	writeConfig(t, path, testConfig(logPath, `{}`, `{}`))
+	a := newTestApp(t, path)
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		// 命令行参数的覆盖在重新加载时同样生效
+		a.watchConfig(path, func(cfg *config.Config) { cfg.Server.PageSize = 7 }, stop)
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	// 等 watchConfig 记录初始状态后再修改，新内容的大小与原来不同
+	time.Sleep(100 * time.Millisecond)
+	writeConfig(t, path, testConfig(logPath, `{"caculator": {"enabled": false}}`, `{}`))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		a.mu.Lock()
+		cfg := a.cfg
+		a.mu.Unlock()
+		if _, ok := a.server.Registry().GetTool("caculator"); !ok {
+			if cfg.Server.PageSize != 7 {
+				t.Errorf("page size = %d, want the override 7", cfg.Server.PageSize)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("config change was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
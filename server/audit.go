@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/audit"
+)
+
+// SetAuditLog 开始把每次 tools/call 写入审计日志，传入 nil 停止记录
+func (s *MCPServer) SetAuditLog(auditLog *audit.Logger) {
+	s.auditLog.Store(auditLog)
+}
+
+// auditToolCall 把一次 tools/call 的调用方、参数、结果和耗时写入审计日志。
+// outcome 与 mcp_tool_calls_total 指标的 outcome 标签取值一致。会话 ID 是会话唯一的凭据，只记录它的摘要
+func (s *MCPServer) auditToolCall(ctx context.Context, sess *session, id *json.RawMessage, tool string, arguments map[string]any, outcome, detail string, duration time.Duration) {
+	auditLog := s.auditLog.Load()
+	if auditLog == nil {
+		return
+	}
+
+	entry := audit.Entry{
+		Session:    sessionDigest(sess.id),
+		Tool:       tool,
+		Outcome:    outcome,
+		Error:      detail,
+		DurationMs: float64(duration.Microseconds()) / 1000,
+	}
+	if id != nil {
+		entry.RequestID = string(*id)
+	}
+	sess.mu.RLock()
+	if sess.clientInfo != nil {
+		entry.Client = &audit.Client{Name: sess.clientInfo.Name, Version: sess.clientInfo.Version}
+	}
+	entry.ProtocolVersion = sess.protocolVersion
+	sess.mu.RUnlock()
+
+	if err := auditLog.Record(entry, arguments); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to write audit entry", "tool", tool, "error", err)
+	}
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HTTP 传输相关的常量
+const (
+	SessionIDHeader = "Mcp-Session-Id"
+	httpEndpoint    = "/mcp"
+	maxHTTPBodySize = 4 * 1024 * 1024
+	sseQueueSize    = 64 // 尚未推送到 SSE 流的通知的最大数量
+
+	// 不设置写超时：SSE 流和耗时较长的工具调用都需要长时间写响应
+	httpReadHeaderTimeout = 10 * time.Second
+	httpReadTimeout       = time.Minute
+	httpIdleTimeout       = 2 * time.Minute
+)
+
+// httpSink 把发往客户端的消息分发到对应的 HTTP 响应：
+// 带有等待中请求 ID 的响应交给该请求的 POST，其他消息 (通知) 放入 SSE 队列
+type httpSink struct {
+	mu      sync.Mutex
+	waiters map[string]chan []byte // 请求 ID -> 等待响应的 POST
+	events  chan []byte
+	closed  chan struct{}
+}
+
+func newHTTPSink() *httpSink {
+	return &httpSink{
+		waiters: make(map[string]chan []byte),
+		events:  make(chan []byte, sseQueueSize),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (h *httpSink) Send(message []byte) error {
+	var probe struct {
+		ID     *json.RawMessage `json:"id"`
+		Method string           `json:"method"`
+	}
+	if err := json.Unmarshal(message, &probe); err == nil && probe.ID != nil && probe.Method == "" {
+		h.mu.Lock()
+		waiter, ok := h.waiters[string(*probe.ID)]
+		h.mu.Unlock()
+		if ok {
+			waiter <- message
+			return nil
+		}
+	}
+
+	select {
+	case h.events <- message:
+		return nil
+	case <-h.closed:
+		return fmt.Errorf("session closed")
+	default:
+		return fmt.Errorf("event queue is full, dropping message")
+	}
+}
+
+// wait 为请求 ID 注册一个等待响应的 channel，返回用于取消注册的函数
+func (h *httpSink) wait(id string) (chan []byte, func()) {
+	ch := make(chan []byte, 1)
+	h.mu.Lock()
+	h.waiters[id] = ch
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.waiters, id)
+		h.mu.Unlock()
+	}
+}
+
+func (h *httpSink) close() {
+	select {
+	case <-h.closed:
+	default:
+		close(h.closed)
+	}
+}
+
+// HTTPHandler 返回 MCP Streamable HTTP 传输的处理器：
+//
+//	POST   /mcp           发送一条 JSON-RPC 消息，请求的响应直接在 HTTP 响应体中返回
+//	GET    /mcp           打开 SSE 流，接收服务器主动发送的通知
+//	DELETE /mcp           结束会话
+//	GET    /healthz       健康检查
+//
+// initialize 请求会创建新的会话，会话 ID 通过 Mcp-Session-Id 头返回，后续请求必须携带该头
+func (s *MCPServer) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(httpEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			s.handleHTTPPost(w, r)
+		case http.MethodGet:
+			s.handleHTTPStream(w, r)
+		case http.MethodDelete:
+			s.handleHTTPDelete(w, r)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("GET "+healthEndpoint, s.handleHealth)
+	return s.checkOrigin(mux)
+}
+
+// ListenAndServe 在 addr 上以 HTTP 传输提供服务，并定期清理空闲的会话
+func (s *MCPServer) ListenAndServe(addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.HTTPHandler(),
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		ReadTimeout:       httpReadTimeout,
+		IdleTimeout:       httpIdleTimeout,
+	}
+	go s.expireIdleSessions()
+
+	s.logger.Info("Serving MCP over HTTP", "addr", addr, "endpoint", httpEndpoint)
+	return srv.ListenAndServe()
+}
+
+// SetAllowedOrigins 设置允许访问 HTTP 传输的浏览器来源 (例如 "https://app.example.com")，
+// 回环地址上的来源总是允许的
+func (s *MCPServer) SetAllowedOrigins(origins []string) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.allowedOrigins = slices.Clone(origins)
+}
+
+// checkOrigin 拒绝来自不允许的来源的浏览器请求，防止 DNS rebinding 攻击：
+// 恶意网页把自己的域名解析到 127.0.0.1 后，浏览器会以该网页的 Origin 访问本地服务器。
+// 没有 Origin 头的请求来自非浏览器客户端，不受影响
+func (s *MCPServer) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !s.originAllowed(origin) {
+			s.logger.Warn("Rejected request from disallowed origin", "origin", origin, "path", r.URL.Path)
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *MCPServer) originAllowed(origin string) bool {
+	s.settingsMu.RLock()
+	allowed := slices.Contains(s.allowedOrigins, origin)
+	s.settingsMu.RUnlock()
+	if allowed {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *MCPServer) handleHTTPPost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	var probe struct {
+		ID     *json.RawMessage `json:"id"`
+		Method string           `json:"method"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, nil, ParseErrorCode, "Parse error")
+		return
+	}
+
+	// initialize 成功之后会话才算建立。initialize 失败或客户端提前断开时立即关闭会话，
+	// 不留下只能等待空闲过期的会话
+	initialize := probe.Method == "initialize"
+	established := false
+	var sess *session
+	if initialize {
+		if probe.ID == nil {
+			writeHTTPError(w, http.StatusBadRequest, nil, InvalidRequestCode, "initialize must be a request")
+			return
+		}
+		sess = s.openSession(newHTTPSink())
+		defer func() {
+			if !established {
+				s.closeSession(sess.id)
+			}
+		}()
+	} else {
+		var ok bool
+		sess, ok = s.lookupSession(r.Header.Get(SessionIDHeader))
+		if !ok {
+			writeHTTPError(w, http.StatusNotFound, probe.ID, InvalidRequestCode, "Unknown or missing session")
+			return
+		}
+	}
+	sink := sess.sink.(*httpSink)
+
+	// 通知和客户端发来的响应不需要返回内容
+	if probe.ID == nil || probe.Method == "" {
+		s.processMessage(sess, body)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	ch, cancel := sink.wait(string(*probe.ID))
+	defer cancel()
+	s.processMessage(sess, body)
+
+	select {
+	case response := <-ch:
+		if initialize && isSuccessResponse(response) {
+			established = true
+			w.Header().Set(SessionIDHeader, sess.id)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+	case <-r.Context().Done():
+	}
+}
+
+// isSuccessResponse 判断 response 是否是不带 error 的 JSON-RPC 响应
+func isSuccessResponse(response []byte) bool {
+	var probe struct {
+		Error *ErrorObject `json:"error"`
+	}
+	return json.Unmarshal(response, &probe) == nil && probe.Error == nil
+}
+
+func (s *MCPServer) handleHTTPStream(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.lookupSession(r.Header.Get(SessionIDHeader))
+	if !ok {
+		http.Error(w, "unknown or missing session", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	sink := sess.sink.(*httpSink)
+
+	// 打开 SSE 流的会话不会因空闲而过期
+	sess.streams.Add(1)
+	defer func() {
+		sess.streams.Add(-1)
+		sess.touch()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case message := <-sink.events:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", message)
+			flusher.Flush()
+		case <-sink.closed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *MCPServer) handleHTTPDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(SessionIDHeader)
+	if _, ok := s.lookupSession(id); !ok {
+		http.Error(w, "unknown or missing session", http.StatusNotFound)
+		return
+	}
+	s.closeSession(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, id *json.RawMessage, code int, message string) {
+	response := ResponseMessage{
+		BaseMessage: BaseMessage{JSONRPC: JSONRPCVersion, ID: id},
+		Error:       &ErrorObject{Code: code, Message: message},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
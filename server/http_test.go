@@ -0,0 +1,125 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const initializeRequest = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`
+
+func TestCheckOrigin(t *testing.T) {
+	s := NewMCPServer(nil, io.Discard, nil)
+	s.SetAllowedOrigins([]string{"https://app.example.com"})
+	handler := s.HTTPHandler()
+
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		{origin: "", want: http.StatusOK},
+		{origin: "http://localhost:3000", want: http.StatusOK},
+		{origin: "http://127.0.0.1", want: http.StatusOK},
+		{origin: "http://[::1]:8080", want: http.StatusOK},
+		{origin: "https://app.example.com", want: http.StatusOK},
+		{origin: "https://app.example.com.evil.test", want: http.StatusForbidden},
+		{origin: "http://evil.test", want: http.StatusForbidden},
+		{origin: "null", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, httpEndpoint, strings.NewReader(initializeRequest))
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseIdleSessions(t *testing.T) {
+	s := NewMCPServer(nil, io.Discard, nil)
+	s.SetSessionIdleTimeout(time.Minute)
+	handler := s.HTTPHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, httpEndpoint, strings.NewReader(initializeRequest)))
+	idle := rec.Header().Get(SessionIDHeader)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, httpEndpoint, strings.NewReader(initializeRequest)))
+	streaming := rec.Header().Get(SessionIDHeader)
+	if idle == "" || streaming == "" {
+		t.Fatal("initialize should open sessions")
+	}
+	sess, _ := s.lookupSession(streaming)
+	sess.streams.Add(1)
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		open  map[string]bool
+	}{
+		{name: "before the timeout", after: 30 * time.Second, open: map[string]bool{idle: true, streaming: true, stdioSessionID: true}},
+		{name: "after the timeout", after: 2 * time.Minute, open: map[string]bool{idle: false, streaming: true, stdioSessionID: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.closeIdleSessions(time.Now().Add(tt.after))
+			for id, want := range tt.open {
+				s.sessionsMu.RLock()
+				_, open := s.sessions[id]
+				s.sessionsMu.RUnlock()
+				if open != want {
+					t.Errorf("session %s open = %v, want %v", id, open, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInitializeOpensSessionOnlyOnSuccess(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantSession bool
+	}{
+		{name: "success", body: initializeRequest, wantStatus: http.StatusOK, wantSession: true},
+		{name: "invalid params", body: `{"jsonrpc":"2.0","id":1,"method":"initialize","params":"bad"}`, wantStatus: http.StatusOK},
+		{name: "missing params", body: `{"jsonrpc":"2.0","id":1,"method":"initialize"}`, wantStatus: http.StatusOK},
+		{name: "notification", body: `{"jsonrpc":"2.0","method":"initialize","params":{}}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMCPServer(nil, io.Discard, nil)
+			rec := httptest.NewRecorder()
+			s.HTTPHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, httpEndpoint, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			id := rec.Header().Get(SessionIDHeader)
+			if (id != "") != tt.wantSession {
+				t.Errorf("session header = %q, want a session: %v", id, tt.wantSession)
+			}
+			// stdio 会话之外的会话数
+			s.sessionsMu.RLock()
+			open := len(s.sessions) - 1
+			s.sessionsMu.RUnlock()
+			wantOpen := 0
+			if tt.wantSession {
+				wantOpen = 1
+			}
+			if open != wantOpen {
+				t.Errorf("%d sessions open, want %d", open, wantOpen)
+			}
+		})
+	}
+}
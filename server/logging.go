@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/logging"
+)
+
+// LoggingLevel 是 MCP 定义的日志级别，与 syslog (RFC 5424) 的级别一致
+type LoggingLevel string
+
+const (
+	LoggingLevelDebug     LoggingLevel = "debug"
+	LoggingLevelInfo      LoggingLevel = "info"
+	LoggingLevelNotice    LoggingLevel = "notice"
+	LoggingLevelWarning   LoggingLevel = "warning"
+	LoggingLevelError     LoggingLevel = "error"
+	LoggingLevelCritical  LoggingLevel = "critical"
+	LoggingLevelAlert     LoggingLevel = "alert"
+	LoggingLevelEmergency LoggingLevel = "emergency"
+)
+
+// loggingLevels 按从低到高的顺序列出 MCP 日志级别对应的 slog 级别
+var loggingLevels = []struct {
+	name  LoggingLevel
+	level slog.Level
+}{
+	{LoggingLevelDebug, slog.LevelDebug},
+	{LoggingLevelInfo, slog.LevelInfo},
+	{LoggingLevelNotice, slog.LevelInfo + 2},
+	{LoggingLevelWarning, slog.LevelWarn},
+	{LoggingLevelError, slog.LevelError},
+	{LoggingLevelCritical, slog.LevelError + 4},
+	{LoggingLevelAlert, slog.LevelError + 8},
+	{LoggingLevelEmergency, slog.LevelError + 12},
+}
+
+// slogLevel 返回 MCP 日志级别对应的 slog 级别
+func (l LoggingLevel) slogLevel() (slog.Level, bool) {
+	for _, entry := range loggingLevels {
+		if entry.name == l {
+			return entry.level, true
+		}
+	}
+	return 0, false
+}
+
+// loggingLevelOf 返回不高于 slog 级别 level 的最高 MCP 日志级别
+func loggingLevelOf(level slog.Level) LoggingLevel {
+	name := LoggingLevelDebug
+	for _, entry := range loggingLevels {
+		if level >= entry.level {
+			name = entry.name
+		}
+	}
+	return name
+}
+
+// defaultLoggerName 是没有 component 属性的记录在 notifications/message 中使用的 logger 名称
+const defaultLoggerName = "mcp-server"
+
+type noForwardKey struct{}
+
+// withoutForwarding 标记 ctx 中产生的日志不再转发给客户端。
+// 发送 notifications/message 本身也会记录日志，不加标记会无限递归
+func withoutForwarding(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noForwardKey{}, true)
+}
+
+// LogForwarder 是把服务器日志以 notifications/message 转发给客户端的 slog.Handler，
+// 实现 MCP 的 logging 工具。只有通过 logging/setLevel 订阅了日志的会话会收到通知，
+// 带有 session 属性的记录只发给对应的会话；其余的进程级记录只发给 stdio 会话，
+// stdio 客户端启动了服务器进程，而网络客户端之间互不信任，不应看到其他会话或整个进程的日志。
+// LogForwarder 需要在创建日志记录器时加入 Handler 链 (见 logging.Options.Extra)，
+// 并通过 MCPServer.ForwardLogs 绑定到服务器
+type LogForwarder struct {
+	state  *forwarderState
+	attrs  []groupedAttr // 通过 WithAttrs 添加的属性
+	groups []string      // 通过 WithGroup 打开的分组
+}
+
+type forwarderState struct {
+	server   atomic.Pointer[MCPServer]
+	minLevel atomic.Int64 // 所有会话中最低的订阅级别，没有订阅时为 math.MaxInt64
+}
+
+type groupedAttr struct {
+	groups []string
+	attr   slog.Attr
+}
+
+func NewLogForwarder() *LogForwarder {
+	state := &forwarderState{}
+	state.minLevel.Store(math.MaxInt64)
+	return &LogForwarder{state: state}
+}
+
+// ForwardLogs 把 forwarder 绑定到服务器，并在 initialize 响应中声明 logging 能力
+func (s *MCPServer) ForwardLogs(forwarder *LogForwarder) {
+	s.logForwarder = forwarder.state
+	forwarder.state.server.Store(s)
+	s.updateLogSubscriptions()
+}
+
+func (f *LogForwarder) Enabled(ctx context.Context, level slog.Level) bool {
+	return int64(level) >= f.state.minLevel.Load() && ctx.Value(noForwardKey{}) == nil
+}
+
+func (f *LogForwarder) Handle(ctx context.Context, r slog.Record) error {
+	s := f.state.server.Load()
+	if s == nil || !f.Enabled(ctx, r.Level) {
+		return nil
+	}
+
+	loggerName := defaultLoggerName
+	session := ""
+	data := map[string]any{"message": r.Message}
+	add := func(groups []string, attr slog.Attr) {
+		if len(groups) == 0 {
+			switch attr.Key {
+			case "component":
+				loggerName = attr.Value.String()
+				return
+			case "session":
+				session = attr.Value.String()
+				return
+			}
+		}
+		addAttr(data, groups, attr)
+	}
+	for _, a := range f.attrs {
+		add(a.groups, a.attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		add(f.groups, attr)
+		return true
+	})
+
+	s.forwardLog(r.Level, loggerName, session, data)
+	return nil
+}
+
+func (f *LogForwarder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := &LogForwarder{state: f.state, groups: f.groups}
+	next.attrs = append(next.attrs, f.attrs...)
+	for _, attr := range attrs {
+		next.attrs = append(next.attrs, groupedAttr{groups: f.groups, attr: attr})
+	}
+	return next
+}
+
+func (f *LogForwarder) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return f
+	}
+	groups := append(append([]string{}, f.groups...), name)
+	return &LogForwarder{state: f.state, attrs: f.attrs, groups: groups}
+}
+
+// addAttr 把属性转换为可以序列化为 JSON 的值，放到 data 中 groups 对应的位置
+func addAttr(data map[string]any, groups []string, attr slog.Attr) {
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	for _, group := range groups {
+		nested, ok := data[group].(map[string]any)
+		if !ok {
+			nested = make(map[string]any)
+			data[group] = nested
+		}
+		data = nested
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		nested := make(map[string]any)
+		for _, a := range value.Group() {
+			addAttr(nested, nil, a)
+		}
+		if attr.Key == "" {
+			for k, v := range nested {
+				data[k] = v
+			}
+			return
+		}
+		data[attr.Key] = nested
+	case slog.KindDuration:
+		data[attr.Key] = value.Duration().String()
+	case slog.KindTime:
+		data[attr.Key] = value.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		v := value.Any()
+		if err, ok := v.(error); ok {
+			data[attr.Key] = err.Error()
+		} else if _, err := json.Marshal(v); err != nil {
+			data[attr.Key] = fmt.Sprint(v)
+		} else {
+			data[attr.Key] = v
+		}
+	default:
+		data[attr.Key] = value.Any()
+	}
+}
+
+// forwardLog 把一条日志发给订阅了该级别的会话。digest 是日志中 session 属性的值 (会话 ID 的摘要)，
+// 非空时只发给对应的会话，为空时是进程级的日志，只发给 stdio 会话
+func (s *MCPServer) forwardLog(level slog.Level, loggerName, digest string, data map[string]any) {
+	params := LoggingMessageParams{Level: loggingLevelOf(level), Logger: loggerName, Data: data}
+
+	sess := s.stdio
+	if digest != "" {
+		sess = s.sessionByDigest(digest)
+	}
+	if sess == nil {
+		return
+	}
+	if min, ok := sess.subscribedLogLevel(); ok && level >= min && sess.isInitialized() {
+		// 使用新的 context，发送过程中的日志只带目标会话的属性
+		ctx := withoutForwarding(logging.WithAttrs(context.Background(), slog.String("session", sessionDigest(sess.id))))
+		s.sendNotification(ctx, sess, "notifications/message", params)
+	}
+}
+
+// updateLogSubscriptions 重新计算所有会话中最低的订阅级别，没有会话订阅时 forwarder 不会处理任何记录
+func (s *MCPServer) updateLogSubscriptions() {
+	if s.logForwarder == nil {
+		return
+	}
+	min := int64(math.MaxInt64)
+	for _, sess := range s.sessionList() {
+		if level, ok := sess.subscribedLogLevel(); ok && int64(level) < min {
+			min = int64(level)
+		}
+	}
+	s.logForwarder.minLevel.Store(min)
+}
+
+// handleSetLevel 处理 logging/setLevel 请求，设置会话接收 notifications/message 的最低级别
+func (s *MCPServer) handleSetLevel(ctx context.Context, sess *session, req RequestMessage) {
+	if !sess.isInitialized() {
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InternalErrorCode, Message: "Server not initialized"})
+		return
+	}
+
+	var params SetLevelParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: "Invalid params for logging/setLevel"})
+		return
+	}
+	level, ok := params.Level.slogLevel()
+	if !ok {
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: fmt.Sprintf("Unknown logging level: %s", params.Level)})
+		return
+	}
+
+	s.logger.InfoContext(ctx, "Client log level set", "level", string(params.Level))
+
+	sess.mu.Lock()
+	sess.logLevel = &level
+	sess.mu.Unlock()
+	s.updateLogSubscriptions()
+	s.sendResponse(ctx, sess, req.ID, struct{}{}, nil)
+}
+
+func (sess *session) subscribedLogLevel() (slog.Level, bool) {
+	sess.mu.RLock()
+	defer sess.mu.RUnlock()
+	if sess.logLevel == nil {
+		return 0, false
+	}
+	return *sess.logLevel, true
+}
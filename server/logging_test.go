@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/n8sPxD/mcp-server-demo/logging"
+)
+
+// newForwardingClients 创建把日志转发给客户端的服务器，返回已初始化的 stdio 会话和一个网络会话
+func newForwardingClients(t *testing.T) (stdio, remote *testClient, logger *slog.Logger) {
+	t.Helper()
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelDebug)
+	forwarder := NewLogForwarder()
+	logger = logging.New(io.Discard, logging.Options{Level: level, RedactKeys: []string{"apikey"}, Extra: []slog.Handler{forwarder}})
+
+	out := &syncBuffer{}
+	server := NewMCPServer(nil, out, logger)
+	server.ForwardLogs(forwarder)
+	stdio = &testClient{t: t, server: server, sess: server.stdio, out: out}
+	stdio.initialize(nil)
+	remote = newSessionClient(t, server)
+	return stdio, remote, logger
+}
+
+// forwardedMessages 返回 lines 中 notifications/message 的 data.message 和级别
+func forwardedMessages(lines [][]byte) map[string]LoggingLevel {
+	messages := make(map[string]LoggingLevel)
+	for _, line := range lines {
+		var notif struct {
+			Method string               `json:"method"`
+			Params LoggingMessageParams `json:"params"`
+		}
+		if json.Unmarshal(line, &notif) != nil || notif.Method != "notifications/message" {
+			continue
+		}
+		data, _ := notif.Params.Data.(map[string]any)
+		if message, ok := data["message"].(string); ok {
+			messages[message] = notif.Params.Level
+		}
+	}
+	return messages
+}
+
+func TestLogForwarding(t *testing.T) {
+	stdio, remote, logger := newForwardingClients(t)
+	if resp := stdio.request("logging/setLevel", map[string]any{"level": "warning"}); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if resp := remote.request("logging/setLevel", map[string]any{"level": "debug"}); resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+
+	tagged := func(c *testClient) context.Context {
+		return logging.WithAttrs(context.Background(), slog.String("session", sessionDigest(c.sess.id)))
+	}
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		level      slog.Level
+		wantStdio  bool
+		wantRemote bool
+	}{
+		{name: "remote debug", ctx: tagged(remote), level: slog.LevelDebug, wantRemote: true},
+		{name: "remote error", ctx: tagged(remote), level: slog.LevelError, wantRemote: true},
+		{name: "stdio info below its level", ctx: tagged(stdio), level: slog.LevelInfo},
+		{name: "stdio warning", ctx: tagged(stdio), level: slog.LevelWarn, wantStdio: true},
+		{name: "process-level error", ctx: context.Background(), level: slog.LevelError, wantStdio: true},
+		{name: "process-level debug", ctx: context.Background(), level: slog.LevelDebug},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger.Log(tt.ctx, tt.level, tt.name)
+			if _, got := forwardedMessages(stdio.out.drain())[tt.name]; got != tt.wantStdio {
+				t.Errorf("stdio session received = %v, want %v", got, tt.wantStdio)
+			}
+			if _, got := forwardedMessages(remote.out.drain())[tt.name]; got != tt.wantRemote {
+				t.Errorf("remote session received = %v, want %v", got, tt.wantRemote)
+			}
+		})
+	}
+}
+
+func TestLogForwardingLevelsAndRedaction(t *testing.T) {
+	stdio, remote, logger := newForwardingClients(t)
+	remote.request("logging/setLevel", map[string]any{"level": "notice"})
+	ctx := logging.WithAttrs(context.Background(), slog.String("session", sessionDigest(remote.sess.id)))
+
+	tests := []struct {
+		level slog.Level
+		want  LoggingLevel // 为空表示不转发
+	}{
+		{level: slog.LevelInfo},
+		{level: slog.LevelInfo + 2, want: LoggingLevelNotice},
+		{level: slog.LevelWarn, want: LoggingLevelWarning},
+		{level: slog.LevelError + 4, want: LoggingLevelCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			logger.Log(ctx, tt.level, "message", "apikey", "secret")
+			lines := remote.out.drain()
+			got := forwardedMessages(lines)["message"]
+			if got != tt.want {
+				t.Errorf("forwarded level = %q, want %q", got, tt.want)
+			}
+			for _, line := range lines {
+				var notif struct {
+					Params LoggingMessageParams `json:"params"`
+				}
+				json.Unmarshal(line, &notif)
+				if data, _ := notif.Params.Data.(map[string]any); data != nil && data["apikey"] != "[REDACTED]" {
+					t.Errorf("apikey = %v, want it redacted", data["apikey"])
+				}
+			}
+		})
+	}
+	// stdio 会话没有订阅日志
+	if got := forwardedMessages(stdio.out.drain()); len(got) != 0 {
+		t.Errorf("unsubscribed stdio session received %v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/metrics"
+	"github.com/n8sPxD/mcp-server-demo/tools"
+	"github.com/pkg/errors"
+)
+
+var (
+	requestsTotal = metrics.NewCounterVec("mcp_requests_total",
+		"Number of JSON-RPC requests handled, by method and status (ok or error).", "method", "status")
+	requestDuration = metrics.NewHistogramVec("mcp_request_duration_seconds",
+		"Time spent handling JSON-RPC requests, by method.", nil, "method")
+	requestsInFlight = metrics.NewGauge("mcp_requests_in_flight",
+		"Number of JSON-RPC requests currently being handled.")
+	toolCallsTotal = metrics.NewCounterVec("mcp_tool_calls_total",
+		"Number of tools/call requests, by tool and outcome.", "tool", "outcome")
+	toolCallDuration = metrics.NewHistogramVec("mcp_tool_call_duration_seconds",
+		"Time spent executing tools, by tool.", nil, "tool")
+)
+
+// tools/call 的结果分类
+const (
+	toolOutcomeSuccess     = "success"      // 工具正常返回
+	toolOutcomeError       = "error"        // 工具返回错误或 isError 结果，或输出不符合 outputSchema
+	toolOutcomeTimeout     = "timeout"      // 超过执行时限
+	toolOutcomeRateLimited = "rate_limited" // 被限流拒绝
+	toolOutcomePanic       = "panic"        // 工具 panic
+	toolOutcomeNotFound    = "not_found"    // 工具不存在或已被禁用
+	toolOutcomeRejected    = "rejected"     // 会话未初始化或参数无效，工具没有执行 (只写入审计日志)
+)
+
+// requestStatus 记录请求的响应是否是错误，由 sendResponse 填写
+type requestStatus struct {
+	failed bool
+}
+
+type requestStatusKey struct{}
+
+// observeRequest 开始统计一个请求，返回的函数在请求处理完成后调用
+func observeRequest(ctx context.Context, method string) (context.Context, func()) {
+	status := &requestStatus{}
+	ctx = context.WithValue(ctx, requestStatusKey{}, status)
+	start := time.Now()
+	requestsInFlight.Inc()
+
+	return ctx, func() {
+		requestsInFlight.Dec()
+		requestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		result := "ok"
+		if status.failed {
+			result = "error"
+		}
+		requestsTotal.WithLabelValues(method, result).Inc()
+	}
+}
+
+// markRequestFailed 标记 ctx 对应的请求以 JSON-RPC 错误结束
+func markRequestFailed(ctx context.Context) {
+	if status, ok := ctx.Value(requestStatusKey{}).(*requestStatus); ok {
+		status.failed = true
+	}
+}
+
+// observeToolCall 记录一次工具调用的结果和耗时
+func observeToolCall(tool, outcome string, duration time.Duration) {
+	toolCallsTotal.WithLabelValues(tool, outcome).Inc()
+	toolCallDuration.WithLabelValues(tool).Observe(duration.Seconds())
+}
+
+// metricMethod 返回请求方法在指标中使用的标签值，不支持的方法统一为 "other"，避免客户端制造任意多的标签
+func metricMethod(method string) string {
+	switch method {
+	case "initialize", "shutdown", "tools/call", "tools/list", "logging/setLevel":
+		return method
+	}
+	return "other"
+}
+
+// toolErrorOutcome 返回工具调用错误对应的结果分类
+func toolErrorOutcome(err error) string {
+	var rateLimitErr *tools.RateLimitError
+	var panicErr *tools.PanicError
+	switch {
+	case errors.Is(err, tools.ErrToolNotFound):
+		return toolOutcomeNotFound
+	case errors.Is(err, tools.ErrToolTimeout):
+		return toolOutcomeTimeout
+	case errors.As(err, &rateLimitErr):
+		return toolOutcomeRateLimited
+	case errors.As(err, &panicErr):
+		return toolOutcomePanic
+	}
+	return toolOutcomeError
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultPageSize 是 list 类方法的默认分页大小
+const DefaultPageSize = 50
+
+// cursorPrefix 用于区分不同 list 方法的游标，避免把 tools/list 的游标用在其他方法上
+const cursorPrefix = "v1:"
+
+// encodeCursor 把上一页最后一项的 key 编码成不透明游标。
+// 使用 key 而不是下标，这样在两次请求之间增删条目也不会导致重复或遗漏
+func encodeCursor(kind, lastKey string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + kind + ":" + lastKey))
+}
+
+func decodeCursor(kind, cursor string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.New("invalid cursor")
+	}
+	prefix := cursorPrefix + kind + ":"
+	if !strings.HasPrefix(string(raw), prefix) {
+		return "", errors.New("invalid cursor")
+	}
+	return strings.TrimPrefix(string(raw), prefix), nil
+}
+
+// paginate 按 key 排序后返回 cursor 之后的一页，以及下一页的游标 (没有下一页时为空)
+func paginate[T any](items []T, key func(T) string, kind, cursor string, pageSize int) ([]T, string, error) {
+	sorted := make([]T, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool { return key(sorted[i]) < key(sorted[j]) })
+
+	start := 0
+	if cursor != "" {
+		lastKey, err := decodeCursor(kind, cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(sorted), func(i int) bool { return key(sorted[i]) > lastKey })
+	}
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	end := start + pageSize
+	if end >= len(sorted) {
+		return sorted[start:], "", nil
+	}
+	return sorted[start:end], encodeCursor(kind, key(sorted[end-1])), nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []string{"e", "a", "d", "b", "c"}
+	identity := func(s string) string { return s }
+
+	tests := []struct {
+		name     string
+		pageSize int
+		want     [][]string
+	}{
+		{name: "single page", pageSize: 10, want: [][]string{{"a", "b", "c", "d", "e"}}},
+		{name: "exact fit", pageSize: 5, want: [][]string{{"a", "b", "c", "d", "e"}}},
+		{name: "several pages", pageSize: 2, want: [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{name: "default page size", pageSize: 0, want: [][]string{{"a", "b", "c", "d", "e"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages [][]string
+			cursor := ""
+			for {
+				page, next, err := paginate(items, identity, "tools", cursor, tt.pageSize)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages = append(pages, page)
+				if next == "" {
+					break
+				}
+				if len(pages) > len(items) {
+					t.Fatal("pagination does not terminate")
+				}
+				cursor = next
+			}
+			if !slices.EqualFunc(pages, tt.want, slices.Equal) {
+				t.Errorf("pages = %v, want %v", pages, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaginateStableAcrossChanges(t *testing.T) {
+	identity := func(s string) string { return s }
+	page, cursor, err := paginate([]string{"a", "b", "c", "d"}, identity, "tools", "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(page, []string{"a", "b"}) {
+		t.Fatalf("first page = %v", page)
+	}
+
+	// 两次请求之间删除已返回的条目并新增条目，下一页从上一页最后一项之后继续
+	page, _, err = paginate([]string{"b", "bb", "c", "d"}, identity, "tools", cursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(page, []string{"bb", "c"}) {
+		t.Errorf("second page = %v, want [bb c]", page)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		cursor  string
+		want    string
+		wantErr bool
+	}{
+		{name: "round trip", kind: "tools", cursor: encodeCursor("tools", "weather.current"), want: "weather.current"},
+		{name: "key with separator", kind: "tools", cursor: encodeCursor("tools", "a:b"), want: "a:b"},
+		{name: "wrong kind", kind: "prompts", cursor: encodeCursor("tools", "x"), wantErr: true},
+		{name: "not base64", kind: "tools", cursor: "%%%", wantErr: true},
+		{name: "missing prefix", kind: "tools", cursor: "dG9vbHM6eA", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.kind, tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -64,7 +64,7 @@ type InitializeParams struct {
 	ProcessID        *int              `json:"processId"` // 可以为 null
 	ClientInfo       *ClientInfo       `json:"clientInfo,omitempty"`
 	Capabilities     json.RawMessage   `json:"capabilities,omitempty"`     // 客户端能力，暂时不详细解析
-	Trace            string            `json:"trace,omitempty"`            // "off", "messages", "verbose"
+	Trace            TraceValue        `json:"trace,omitempty"`            // "off", "messages", "verbose"
 	RootURI          *string           `json:"rootUri"`                    // 可以为 null
 	WorkspaceFolders []json.RawMessage `json:"workspaceFolders,omitempty"` // 暂时不详细解析
 }
@@ -75,9 +75,18 @@ type ServerInfo struct {
 	Version string `json:"version,omitempty"`
 }
 
+// ToolsCapability 描述服务器对工具的支持
+type ToolsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"` // 工具集合变化时是否发送 notifications/tools/list_changed
+}
+
+// LoggingCapability 表示服务器支持 logging/setLevel 和 notifications/message
+type LoggingCapability struct{}
+
 // ServerCapabilities 定义了服务器的能力
 type ServerCapabilities struct {
-	Tools map[string]tools.ToolDefinition `json:"tools,omitempty"`
+	Tools   *ToolsCapability   `json:"tools,omitempty"`
+	Logging *LoggingCapability `json:"logging,omitempty"`
 	// 可以添加其他能力，例如 textDocumentSync, completionProvider 等
 }
 
@@ -97,10 +106,35 @@ type ShutdownParams struct{}
 // ExitParams 是 exit 通知的参数 (通常为空或null)
 type ExitParams struct{}
 
-// ListToolsParams 是 tools/list 请求的参数 (通常为空，但为完整性添加)
-type ListToolsParams struct{}
+// PaginatedParams 是所有 list 类请求共用的分页参数
+type PaginatedParams struct {
+	Cursor string `json:"cursor,omitempty"` // 上一次响应返回的 nextCursor
+}
+
+// PaginatedResult 是所有 list 类响应共用的分页字段
+type PaginatedResult struct {
+	NextCursor string `json:"nextCursor,omitempty"` // 为空表示没有更多数据
+}
+
+// ListToolsParams 是 tools/list 请求的参数
+type ListToolsParams struct {
+	PaginatedParams
+}
 
 // ListToolsResult 是 tools/list 请求成功时的结果
 type ListToolsResult struct {
+	PaginatedResult
 	Tools []tools.ToolDefinition `json:"tools"`
 }
+
+// SetLevelParams 是 logging/setLevel 请求的参数
+type SetLevelParams struct {
+	Level LoggingLevel `json:"level"`
+}
+
+// LoggingMessageParams 是 notifications/message 通知的参数
+type LoggingMessageParams struct {
+	Level  LoggingLevel `json:"level"`
+	Logger string       `json:"logger,omitempty"`
+	Data   any          `json:"data"`
+}
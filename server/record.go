@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/logging"
+	"github.com/pkg/errors"
+)
+
+// 消息方向，用于 trace 日志和录制文件
+const (
+	DirectionReceived = "received" // 客户端发给服务器的消息
+	DirectionSent     = "sent"     // 服务器发给客户端的消息
+)
+
+// RecordEntry 是录制文件中的一行，描述一条收到或发出的 JSON-RPC 消息
+type RecordEntry struct {
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"direction"` // DirectionReceived 或 DirectionSent
+	Session   string          `json:"session"`
+	Message   json.RawMessage `json:"message,omitempty"`
+	Raw       string          `json:"raw,omitempty"` // 消息不是合法的 JSON 时保存原始文本
+}
+
+// Payload 返回消息的原始字节
+func (e *RecordEntry) Payload() []byte {
+	if len(e.Message) > 0 {
+		return e.Message
+	}
+	return []byte(e.Raw)
+}
+
+// Recorder 把会话收发的每条消息以 JSONL 格式追加写入文件，用于事后重放和复现问题。
+// 消息在写入前按 redactor 脱敏，因此重放时脱敏字段的值与原始会话不同
+type Recorder struct {
+	mu       sync.Mutex
+	w        io.Writer
+	closer   io.Closer
+	redactor *logging.Redactor
+}
+
+// NewRecorder 创建写入 w 的 Recorder，redactor 为 nil 时使用默认的脱敏规则
+func NewRecorder(w io.Writer, redactor *logging.Redactor) *Recorder {
+	if redactor == nil {
+		redactor = logging.NewRedactor(nil, nil)
+	}
+	return &Recorder{w: w, redactor: redactor}
+}
+
+// OpenRecorder 以追加方式打开录制文件，redactor 为 nil 时使用默认的脱敏规则
+func OpenRecorder(path string, redactor *logging.Redactor) (*Recorder, error) {
+	// 录制的消息中有工具参数和结果，只允许所有者读写
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open recording file")
+	}
+	recorder := NewRecorder(file, redactor)
+	recorder.closer = file
+	return recorder, nil
+}
+
+// Record 脱敏后写入一条消息
+func (r *Recorder) Record(direction, sessionID string, message []byte) error {
+	entry := RecordEntry{Time: time.Now().UTC(), Direction: direction, Session: sessionID}
+	// 以 json.Number 解码数字，避免大整数 ID 在重新编码后失真
+	var decoded any
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err == nil && !decoder.More() {
+		redacted, err := json.Marshal(r.redactor.Value(decoded))
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal redacted message")
+		}
+		entry.Message = redacted
+	} else {
+		entry.Raw = r.redactor.String(string(message))
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal recording entry")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close 关闭录制文件
+func (r *Recorder) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// ReadRecording 读取录制文件中的全部消息
+func ReadRecording(reader io.Reader) ([]RecordEntry, error) {
+	var entries []RecordEntry
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry RecordEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.Wrapf(err, "invalid recording entry on line %d", line)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read recording")
+	}
+	return entries, nil
+}
+
+// SetRecorder 开始把所有会话收发的消息写入 recorder，传入 nil 停止录制
+func (s *MCPServer) SetRecorder(recorder *Recorder) {
+	s.recorder.Store(recorder)
+}
+
+// observeMessage 在消息被处理或发出时调用，负责 trace 日志和录制
+func (s *MCPServer) observeMessage(ctx context.Context, sess *session, direction string, message []byte) {
+	s.traceMessage(ctx, sess, direction, message)
+	if recorder := s.recorder.Load(); recorder != nil {
+		if err := recorder.Record(direction, sess.id, message); err != nil {
+			s.logger.ErrorContext(ctx, "Failed to record message", "error", err)
+		}
+	}
+}
@@ -1,41 +1,102 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"os"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/n8sPxD/mcp-server-demo/audit"
+	"github.com/n8sPxD/mcp-server-demo/logging"
 	"github.com/n8sPxD/mcp-server-demo/tools"
+	"github.com/n8sPxD/mcp-server-demo/tracing"
+	"github.com/pkg/errors"
 )
 
 // MCPServer 定义了 MCP 服务器的状态和能力
 type MCPServer struct {
-	reader      io.Reader
-	writer      io.Writer
-	logger      *log.Logger
-	file        *os.File
-	tools       tools.ToolsMap
-	initialized bool
+	reader io.Reader
+	logger *slog.Logger
+	tools  *tools.Registry
 
+	settingsMu   sync.RWMutex // 保护可在运行时修改的设置
+	pageSize     int          // list 类方法每页返回的条目数
+	serverInfo   ServerInfo
+	defaultTrace TraceValue // initialize 中没有指定 trace 的会话使用的级别
+
+	allowedOrigins     []string      // 允许访问 HTTP 传输的浏览器来源，见 SetAllowedOrigins
+	sessionIdleTimeout time.Duration // 网络会话的空闲时限，见 SetSessionIdleTimeout
+
+	logForwarder *forwarderState              // 为 nil 表示不支持 MCP logging 工具，见 ForwardLogs
+	recorder     atomic.Pointer[Recorder]     // 为 nil 表示不录制消息，见 SetRecorder
+	auditLog     atomic.Pointer[audit.Logger] // 为 nil 表示不记录审计日志，见 SetAuditLog
+
+	stdio      *session // 绑定到 reader/writer 的默认会话
+	sessionsMu sync.RWMutex
+	sessions   map[string]*session
+
+	startedAt      time.Time
 	ShutdownSignal chan struct{} // 用于通知主循环服务器已关闭
+	shutdownOnce   sync.Once
 }
 
-func NewMCPServer(reader io.Reader, writer io.Writer, file *os.File) *MCPServer {
-	return &MCPServer{
-		reader:         reader,
-		writer:         writer,
-		file:           file,
-		logger:         log.New(file, "[MCP Server] ", log.LstdFlags),
-		tools:          make(map[string]tools.ToolDefinition),
-		initialized:    false,
-		ShutdownSignal: make(chan struct{}),
+// NewMCPServer 创建在 reader/writer 上提供 stdio 会话的服务器，logger 为 nil 时不记录日志
+func NewMCPServer(reader io.Reader, writer io.Writer, logger *slog.Logger) *MCPServer {
+	if logger == nil {
+		logger = logging.Discard()
+	}
+	s := &MCPServer{
+		reader:             reader,
+		logger:             logger.With("component", "server"),
+		tools:              tools.NewDefaultRegistry(),
+		pageSize:           DefaultPageSize,
+		defaultTrace:       TraceOff,
+		sessionIdleTimeout: DefaultSessionIdleTimeout,
+		serverInfo:         ServerInfo{Name: "mcp-go-weather-server", Version: "0.0.1"},
+		stdio:              newSession(stdioSessionID, &lineSink{writer: writer}),
+		sessions:           make(map[string]*session),
+		startedAt:          time.Now(),
+		ShutdownSignal:     make(chan struct{}),
 	}
+	s.sessions[s.stdio.id] = s.stdio
+	s.tools.OnChange(s.notifyToolListChanged)
+	s.tools.Use(tools.Logging(s.logger), tools.Timing(s.logger))
+	return s
+}
+
+// Registry 返回服务器使用的工具注册表，可用于在运行时增删工具
+func (s *MCPServer) Registry() *tools.Registry {
+	return s.tools
+}
+
+// Shutdown 关闭 ShutdownSignal，可以被安全地多次调用
+func (s *MCPServer) Shutdown() {
+	s.shutdownOnce.Do(func() { close(s.ShutdownSignal) })
+}
+
+// SetServerInfo 设置在 initialize 响应中返回的服务器名称和版本
+func (s *MCPServer) SetServerInfo(name, version string) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.serverInfo = ServerInfo{Name: name, Version: version}
+}
+
+// SetPageSize 设置 list 类方法的分页大小，非正数表示使用默认值
+func (s *MCPServer) SetPageSize(size int) {
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.pageSize = size
 }
 
 // sendResponse 发送 JSON-RPC 响应
-func (s *MCPServer) sendResponse(id *json.RawMessage, result any, err *ErrorObject) {
+func (s *MCPServer) sendResponse(ctx context.Context, sess *session, id *json.RawMessage, result any, err *ErrorObject) {
 	response := ResponseMessage{
 		BaseMessage: BaseMessage{
 			JSONRPC: JSONRPCVersion,
@@ -44,12 +105,16 @@ func (s *MCPServer) sendResponse(id *json.RawMessage, result any, err *ErrorObje
 	}
 	if err != nil {
 		response.Error = err
+		markRequestFailed(ctx)
+		recordErrorResponse(ctx, err)
 	} else {
 		resultBytes, marshalErr := json.Marshal(result)
 		if marshalErr != nil {
-			s.logger.Printf("Error marshalling result: %v", marshalErr)
+			s.logger.ErrorContext(ctx, "Error marshalling result", "error", marshalErr)
 			// Fallback to sending an internal error if marshalling the actual result fails
 			response.Error = &ErrorObject{Code: InternalErrorCode, Message: "Error marshalling result"}
+			markRequestFailed(ctx)
+			recordErrorResponse(ctx, response.Error)
 			response.Result = nil // Clear any potentially partially set result
 		} else {
 			response.Result = resultBytes
@@ -58,78 +123,70 @@ func (s *MCPServer) sendResponse(id *json.RawMessage, result any, err *ErrorObje
 
 	responseBytes, marshalErr := json.Marshal(response)
 	if marshalErr != nil {
-		s.logger.Printf("Error marshalling response: %v", marshalErr)
+		s.logger.ErrorContext(ctx, "Error marshalling response", "error", marshalErr)
 		// Cannot send a response if we can't marshal the response itself.
 		// Log and potentially panic or exit, depending on desired robustness.
 		return
 	}
 
-	// 打印格式化后响应
-	prettyResponse, _ := json.MarshalIndent(response, "", "  ")
-	s.logger.Printf("Sending formatted response: %s\n", string(prettyResponse))
+	s.observeMessage(ctx, sess, DirectionSent, responseBytes)
 
-	// 直接写入响应体
-	if _, writeErr := s.writer.Write(responseBytes); writeErr != nil {
-		s.logger.Printf("Error writing body: %v", writeErr)
-		return // 如果写入响应体失败，也应该返回
-	}
-	// 在响应体后写入换行符
-	if _, writeErr := s.writer.Write([]byte("\n")); writeErr != nil {
-		s.logger.Printf("Error writing newline: %v", writeErr)
-		return
-	}
-
-	if flusher, ok := s.writer.(interface{ Flush() error }); ok {
-		if err := flusher.Flush(); err != nil {
-			s.logger.Printf("Error flushing writer: %v", err)
-		}
+	if writeErr := sess.writeMessage(responseBytes); writeErr != nil {
+		s.logger.ErrorContext(ctx, "Error writing response", "error", writeErr)
 	}
 }
 
-// sendNotification 发送 JSON-RPC 通知 (这里未使用，但可以按需添加)
-/*
-func (s *MCPServer) sendNotification(method string, params any) {
+// sendNotification 发送 JSON-RPC 通知
+func (s *MCPServer) sendNotification(ctx context.Context, sess *session, method string, params any) {
 	notification := NotificationMessage{
 		JSONRPC: JSONRPCVersion,
 		Method:  method,
 	}
 	if params != nil {
-		paramsBytes, _ := json.Marshal(params)
+		paramsBytes, err := json.Marshal(params)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Error marshalling notification params", "method", method, "error", err)
+			return
+		}
 		notification.Params = paramsBytes
 	}
-	notificationBytes, _ := json.Marshal(notification)
-	s.logger.Printf("Sending notification: %s\n", string(notificationBytes))
 
-	header := fmt.Sprintf("Content-Length: %d\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n", len(notificationBytes))
-	s.writer.Write([]byte(header))
-	s.writer.Write(notificationBytes)
-	if flusher, ok := s.writer.(interface{ Flush() error }); ok {
-		flusher.Flush()
+	notificationBytes, err := json.Marshal(notification)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error marshalling notification", "method", method, "error", err)
+		return
+	}
+	s.observeMessage(ctx, sess, DirectionSent, notificationBytes)
+
+	if err := sess.writeMessage(notificationBytes); err != nil {
+		s.logger.ErrorContext(ctx, "Error writing notification", "session", sessionDigest(sess.id), "notification", method, "error", err)
+	}
+}
+
+// notifyToolListChanged 向所有已初始化的会话发送 notifications/tools/list_changed
+func (s *MCPServer) notifyToolListChanged() {
+	for _, sess := range s.sessionList() {
+		if sess.isInitialized() {
+			ctx := logging.WithAttrs(context.Background(), slog.String("session", sessionDigest(sess.id)))
+			s.sendNotification(ctx, sess, "notifications/tools/list_changed", nil)
+		}
 	}
 }
-*/
 
 // handleInitialize 处理 initialize 请求
-func (s *MCPServer) handleInitialize(req RequestMessage) {
+func (s *MCPServer) handleInitialize(ctx context.Context, sess *session, req RequestMessage) {
 	var params InitializeParams // <--- 用于解析请求参数
 	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.logger.Printf("Error unmarshalling initialize params: %v", err)
-		s.sendResponse(req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: "Invalid params for initialize"})
+		s.logger.WarnContext(ctx, "Error unmarshalling initialize params", "error", err)
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: "Invalid params for initialize"})
 		return
 	}
-	s.logger.Printf("Initialize params: %+v\n", params) // 打印解析后的参数
-
-	serverInfo := ServerInfo{
-		Name:    "mcp-go-weather-server",
-		Version: "0.0.1",
-	}
-
-	// 将 Tools 从数组改为 map
-	toolsMap := tools.NewToolsMap()
-	s.tools = toolsMap
 
 	capabilities := ServerCapabilities{
-		Tools: s.tools, // <--- 使用 s.tools
+		Tools: &ToolsCapability{ListChanged: true}, // 工具集合可能在运行时变化
+	}
+	if s.logForwarder != nil {
+		capabilities.Logging = &LoggingCapability{}
 	}
 
 	// 从客户端参数中获取 protocolVersion，如果不存在则使用默认值
@@ -137,100 +194,207 @@ func (s *MCPServer) handleInitialize(req RequestMessage) {
 	if params.ProtocolVersion != nil {
 		clientProtocolVersion = *params.ProtocolVersion
 	}
-	s.logger.Printf("Client requested protocol version: %s", clientProtocolVersion)
+	clientName := ""
+	if params.ClientInfo != nil {
+		clientName = params.ClientInfo.Name
+	}
+	s.logger.InfoContext(ctx, "Client initializing", "client", clientName, "protocolVersion", clientProtocolVersion)
+
+	sess.mu.Lock()
+	sess.clientInfo = params.ClientInfo
+	sess.protocolVersion = clientProtocolVersion
+	sess.mu.Unlock()
+	if params.Trace != "" {
+		s.setSessionTrace(ctx, sess, params.Trace)
+	}
+
+	s.settingsMu.RLock()
+	serverInfo := s.serverInfo
+	s.settingsMu.RUnlock()
 
 	result := InitializeResult{
 		ProtocolVersion: clientProtocolVersion, // <--- 设置 ProtocolVersion
 		ServerInfo:      serverInfo,
 		Capabilities:    capabilities,
 	}
-	s.sendResponse(req.ID, result, nil)
+	s.sendResponse(ctx, sess, req.ID, result, nil)
 }
 
 // handleInitialized 处理 initialized 通知
-func (s *MCPServer) handleInitialized(notif NotificationMessage) {
-	s.logger.Println("Server initialized by client.")
-	s.initialized = true
+func (s *MCPServer) handleInitialized(ctx context.Context, sess *session, notif NotificationMessage) {
+	s.logger.InfoContext(ctx, "Session initialized by client")
+	sess.setInitialized()
 	// 可以在这里执行初始化后的操作
 }
 
 // handleShutdown 处理 shutdown 请求
-func (s *MCPServer) handleShutdown(req RequestMessage) {
-	s.logger.Println("Shutdown request received.")
-	s.sendResponse(req.ID, nil, nil) // 回复空结果
+func (s *MCPServer) handleShutdown(ctx context.Context, sess *session, req RequestMessage) {
+	s.logger.InfoContext(ctx, "Shutdown request received")
+	s.sendResponse(ctx, sess, req.ID, nil, nil) // 回复空结果
 	// 准备关闭，但不立即退出，等待 exit 通知
 }
 
 // handleExit 处理 exit 通知
-func (s *MCPServer) handleExit(notif NotificationMessage) {
-	s.logger.Println("Exit notification received. Server shutting down.")
-	close(s.ShutdownSignal) // 发送关闭信号
+func (s *MCPServer) handleExit(ctx context.Context, sess *session, notif NotificationMessage) {
+	s.logger.InfoContext(ctx, "Exit notification received, server shutting down")
+	s.Shutdown() // 发送关闭信号
 }
 
 // handleExecuteTool 处理 tools/call 请求
-func (s *MCPServer) handleExecuteTool(req RequestMessage) {
-	if !s.initialized {
-		s.sendResponse(req.ID, nil, &ErrorObject{Code: InternalErrorCode, Message: "Server not initialized"})
+func (s *MCPServer) handleExecuteTool(ctx context.Context, sess *session, req RequestMessage) {
+	var params tools.ExecuteToolParams
+	paramsErr := json.Unmarshal(req.Params, &params)
+
+	// 被拒绝的调用同样写入审计日志，参数无效时工具名和参数尽量从能解析的部分取得
+	if !sess.isInitialized() {
+		s.auditToolCall(ctx, sess, req.ID, params.ToolName, params.Inputs, toolOutcomeRejected, "server not initialized", 0)
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InternalErrorCode, Message: "Server not initialized"})
+		return
+	}
+	if paramsErr != nil {
+		s.auditToolCall(ctx, sess, req.ID, params.ToolName, params.Inputs, toolOutcomeRejected, "invalid params", 0)
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: "Invalid params for tools/call"})
 		return
 	}
 
-	var params tools.ExecuteToolParams
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.sendResponse(req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: "Invalid params for tools/call"})
+	toolDef, ok := s.tools.GetTool(params.ToolName)
+	if !ok {
+		// 未知工具属于协议错误。名称由客户端决定，只写入审计日志，不计入指标
+		s.auditToolCall(ctx, sess, req.ID, params.ToolName, params.Inputs, toolOutcomeNotFound, "unknown tool", 0)
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: fmt.Sprintf("Unknown tool: %s", params.ToolName)})
 		return
 	}
 
-	s.logger.Printf("Executing tool: %s with inputs: %+v\n", params.ToolName, params.Inputs)
+	// 参数不符合 inputSchema 属于协议错误，不交给工具执行。工具收到的是通过校验的同一份参数
+	inputs := params.Inputs
+	if inputs == nil {
+		inputs = map[string]any{}
+	}
+	if err := tools.ValidateAgainstSchema(toolDef.InputSchema, inputs); err != nil {
+		s.auditToolCall(ctx, sess, req.ID, params.ToolName, params.Inputs, toolOutcomeRejected, err.Error(), 0)
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: fmt.Sprintf("Invalid arguments for tool '%s': %v", params.ToolName, err)})
+		return
+	}
 
-	if toolFunc, ok := tools.ToolFuncMap[params.ToolName]; ok {
-		content, err := toolFunc(params.Inputs)
-		if err != nil {
-			s.sendResponse(req.ID, nil, &ErrorObject{Code: InternalErrorCode, Message: err.Error()})
+	ctx = tools.WithSessionID(ctx, sess.id)
+	toolCtx, toolSpan := tracing.Start(ctx, "execute_tool "+params.ToolName, tracing.KindInternal,
+		tracing.Attr("gen_ai.operation.name", "execute_tool"),
+		tracing.Attr("gen_ai.tool.name", params.ToolName),
+	)
+	start := time.Now()
+	content, err := s.tools.Call(toolCtx, params.ToolName, inputs)
+	elapsed := time.Since(start)
+	if err == nil && content == nil {
+		// 插件或下游可能返回空结果，按工具错误处理，避免后面解引用 nil
+		content = tools.NewErrorResult(errors.New("tool returned no result"))
+	}
+	switch {
+	case err != nil:
+		toolSpan.SetAttributes(tracing.Attr("error.type", toolErrorOutcome(err)))
+		toolSpan.RecordError(err)
+	case content.IsError:
+		toolSpan.SetStatus(tracing.StatusError, "tool returned an error result")
+	}
+	toolSpan.End()
+	// finish 记录调用的最终结果，每个返回路径调用一次
+	finish := func(outcome, detail string) {
+		observeToolCall(params.ToolName, outcome, elapsed)
+		s.auditToolCall(ctx, sess, req.ID, params.ToolName, inputs, outcome, detail, elapsed)
+	}
+	if err != nil {
+		finish(toolErrorOutcome(err), err.Error())
+		if errors.Is(err, tools.ErrToolNotFound) {
+			// 工具在查找之后被删除或禁用
+			s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: fmt.Sprintf("Unknown tool: %s", params.ToolName)})
 			return
 		}
-		s.sendResponse(req.ID, content, nil)
+		var panicErr *tools.PanicError
+		if errors.As(err, &panicErr) {
+			s.logger.ErrorContext(ctx, "Recovered from panic in tool", "tool", panicErr.Tool,
+				"panics", tools.PanicCounts()[panicErr.Tool], "panic", fmt.Sprint(panicErr.Value), "stack", string(panicErr.Stack))
+		}
+		var rateLimitErr *tools.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			s.sendResponse(ctx, sess, req.ID, tools.NewRateLimitResult(rateLimitErr), nil)
+			return
+		}
+		// 工具执行错误作为 isError 结果返回，而不是 JSON-RPC 错误
+		s.sendResponse(ctx, sess, req.ID, tools.NewErrorResult(err), nil)
+		return
+	}
+
+	// 声明了 outputSchema 的工具必须返回符合 schema 的结构化输出
+	if toolDef.OutputSchema != nil && !content.IsError {
+		if content.StructuredContent == nil {
+			finish(toolOutcomeError, "no structured content")
+			s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InternalErrorCode, Message: fmt.Sprintf("Tool '%s' declares an outputSchema but returned no structured content", params.ToolName)})
+			return
+		}
+		if err := tools.ValidateAgainstSchema(*toolDef.OutputSchema, content.StructuredContent); err != nil {
+			s.logger.ErrorContext(ctx, "Tool returned invalid structured content", "tool", params.ToolName, "error", err)
+			finish(toolOutcomeError, "structured content does not match outputSchema: "+err.Error())
+			s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InternalErrorCode, Message: fmt.Sprintf("Tool '%s' returned structured content that does not match its outputSchema: %v", params.ToolName, err)})
+			return
+		}
+	}
+	if content.IsError {
+		finish(toolOutcomeError, "tool returned an error result")
 	} else {
-		s.sendResponse(req.ID, nil, &ErrorObject{Code: MethodNotFoundCode, Message: fmt.Sprintf("Tool '%s' not found", params.ToolName)})
+		finish(toolOutcomeSuccess, "")
 	}
+	s.sendResponse(ctx, sess, req.ID, content, nil)
 }
 
 // handleListTools 处理 tools/list 请求
-func (s *MCPServer) handleListTools(req RequestMessage) {
-	if !s.initialized {
-		s.sendResponse(req.ID, nil, &ErrorObject{Code: InternalErrorCode, Message: "Server not initialized"})
+func (s *MCPServer) handleListTools(ctx context.Context, sess *session, req RequestMessage) {
+	if !sess.isInitialized() {
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InternalErrorCode, Message: "Server not initialized"})
 		return
 	}
 
-	s.logger.Println("ListTools request received.")
+	var params ListToolsParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: "Invalid params for tools/list"})
+			return
+		}
+	}
+
+	s.logger.DebugContext(ctx, "ListTools request received", "cursor", params.Cursor)
 
-	// 将 s.tools (map) 转换为 []ToolDefinition
-	var toolsArray []tools.ToolDefinition
-	for _, toolDef := range s.tools {
-		toolsArray = append(toolsArray, toolDef)
+	s.settingsMu.RLock()
+	pageSize := s.pageSize
+	s.settingsMu.RUnlock()
+
+	page, nextCursor, err := paginate(s.tools.List(), func(t tools.ToolDefinition) string { return t.Name }, "tools", params.Cursor, pageSize)
+	if err != nil {
+		s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: InvalidParamsCode, Message: err.Error()})
+		return
 	}
 
 	result := ListToolsResult{
-		Tools: toolsArray, // <--- 使用转换后的数组
+		PaginatedResult: PaginatedResult{NextCursor: nextCursor},
+		Tools:           page,
 	}
-	s.sendResponse(req.ID, result, nil)
+	s.sendResponse(ctx, sess, req.ID, result, nil)
 }
 
-// processMessage 解析并处理单个消息
+// ProcessMessage 在 stdio 会话上解析并处理单个消息
 func (s *MCPServer) ProcessMessage(rawMessage []byte) {
-	// 打印格式化后的消息
-	var tempMarshalMap map[string]interface{}
-	if err := json.Unmarshal(rawMessage, &tempMarshalMap); err == nil {
-		prettyMessage, _ := json.MarshalIndent(tempMarshalMap, "", "  ")
-		s.logger.Printf("DEBUG: Received message line: %s", string(prettyMessage)) // 调试日志
-	} else {
-		s.logger.Printf("DEBUG: Received message line: %s", string(rawMessage)) // 调试日志
-	}
+	s.processMessage(s.stdio, rawMessage)
+}
+
+// processMessage 解析并处理某个会话上的单个消息。
+// 会话 ID、方法名和请求 ID 作为日志属性附加在 context 上，处理过程中的日志都会带上它们
+func (s *MCPServer) processMessage(sess *session, rawMessage []byte) {
+	ctx := logging.WithAttrs(context.Background(), slog.String("session", sessionDigest(sess.id)))
+	s.observeMessage(ctx, sess, DirectionReceived, rawMessage)
 
 	// 首先尝试解析基本结构，以判断是请求还是通知 (通过有无ID)
 	var base BaseMessage
 	if err := json.Unmarshal(rawMessage, &base); err != nil {
 		// 如果连基本结构都无法解析，记录错误。无法确定ID，无法响应。
-		s.logger.Printf("Failed to parse base JSON message: %v. Raw: %s\n", err, string(rawMessage))
+		s.logger.WarnContext(ctx, "Failed to parse base JSON message", "error", err, "raw", string(rawMessage))
 		return
 	}
 
@@ -238,53 +402,52 @@ func (s *MCPServer) ProcessMessage(rawMessage []byte) {
 		var req RequestMessage
 		// 再次解析为完整的 RequestMessage 结构
 		if err := json.Unmarshal(rawMessage, &req); err == nil && req.Method != "" {
-			s.logger.Printf("Parsed as Request: ID=%s, Method=%s\n", string(*req.ID), req.Method)
-			// 打印格式化的请求内容
-			if s.logger != nil {
-				prettyReq, _ := json.MarshalIndent(req, "", "  ")
-				s.logger.Printf("Received formatted request: %s\n", string(prettyReq))
-			}
+			ctx = logging.WithAttrs(ctx, slog.String("requestId", string(*req.ID)), slog.String("method", req.Method))
+			ctx, done := observeRequest(ctx, metricMethod(req.Method))
+			defer done()
+			ctx, span := startRequestSpan(ctx, sess, req)
+			defer span.End()
 
 			switch req.Method {
 			case "initialize":
-				s.handleInitialize(req)
+				s.handleInitialize(ctx, sess, req)
 			case "shutdown":
-				s.handleShutdown(req)
+				s.handleShutdown(ctx, sess, req)
 			case "tools/call":
-				s.handleExecuteTool(req)
+				s.handleExecuteTool(ctx, sess, req)
 			case "tools/list":
-				s.handleListTools(req)
+				s.handleListTools(ctx, sess, req)
+			case "logging/setLevel":
+				s.handleSetLevel(ctx, sess, req)
 			default:
-				s.logger.Printf("Unknown request method: %s\n", req.Method)
-				s.sendResponse(req.ID, nil, &ErrorObject{Code: MethodNotFoundCode, Message: "Method not found: " + req.Method})
+				s.logger.WarnContext(ctx, "Unknown request method")
+				s.sendResponse(ctx, sess, req.ID, nil, &ErrorObject{Code: MethodNotFoundCode, Message: "Method not found: " + req.Method})
 			}
 		} else {
 			// 有ID但无法解析为有效请求 (例如，缺少method字段)
-			s.logger.Printf("Received message with ID that is not a valid request structure. Raw: %s, Parse Err: %v\n", string(rawMessage), err)
-			s.sendResponse(base.ID, nil, &ErrorObject{Code: InvalidRequestCode, Message: "Invalid Request"})
+			ctx = logging.WithAttrs(ctx, slog.String("requestId", string(*base.ID)))
+			s.logger.WarnContext(ctx, "Received message with ID that is not a valid request", "error", err, "raw", string(rawMessage))
+			s.sendResponse(ctx, sess, base.ID, nil, &ErrorObject{Code: InvalidRequestCode, Message: "Invalid Request"})
 		}
 	} else { // 没有 ID，说明是通知
 		var notif NotificationMessage
 		if err := json.Unmarshal(rawMessage, &notif); err == nil && notif.Method != "" {
-			s.logger.Printf("Parsed as Notification: Method=%s\n", notif.Method)
-			// 打印格式化的通知内容
-			if s.logger != nil {
-				prettyNotif, _ := json.MarshalIndent(notif, "", "  ")
-				s.logger.Printf("Received formatted notification: %s\n", string(prettyNotif))
-			}
+			ctx = logging.WithAttrs(ctx, slog.String("method", notif.Method))
 
 			switch notif.Method {
 			case "initialized", "notifications/initialized":
-				s.handleInitialized(notif)
+				s.handleInitialized(ctx, sess, notif)
 			case "exit":
-				s.handleExit(notif)
+				s.handleExit(ctx, sess, notif)
+			case "$/setTrace":
+				s.handleSetTrace(ctx, sess, notif)
 			// 可以添加其他通知处理，例如 $/cancelRequest
 			default:
-				s.logger.Printf("Unknown notification method: %s\n", notif.Method)
+				s.logger.DebugContext(ctx, "Unknown notification method")
 			}
 		} else {
 			// 没有ID，并且无法解析为有效的通知结构
-			s.logger.Printf("Failed to parse message as Notification (and it had no ID). Raw: %s, Parse Err: %v\n", string(rawMessage), err)
+			s.logger.WarnContext(ctx, "Failed to parse message as notification", "error", err, "raw", string(rawMessage))
 			// 对于无法解析的通知，通常不发送响应
 		}
 	}
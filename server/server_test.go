@@ -0,0 +1,379 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/n8sPxD/mcp-server-demo/tools"
+	"github.com/pkg/errors"
+)
+
+// syncBuffer 是可以被多个 goroutine 同时写入的缓冲区
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// drain 取出目前写入的所有消息
+func (b *syncBuffer) drain() [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(&b.buf)
+	for scanner.Scan() {
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+	b.buf.Reset()
+	return lines
+}
+
+// testClient 通过服务器的一个会话驱动服务器，out 收集发给该会话的消息
+type testClient struct {
+	t      *testing.T
+	server *MCPServer
+	sess   *session
+	out    *syncBuffer
+	nextID int
+}
+
+// newTestClient 创建服务器并在 stdio 会话上完成 initialize 握手
+func newTestClient(t *testing.T) *testClient {
+	t.Helper()
+	out := &syncBuffer{}
+	server := NewMCPServer(nil, out, nil)
+	c := &testClient{t: t, server: server, sess: server.stdio, out: out}
+	c.initialize(nil)
+	return c
+}
+
+// newSessionClient 在 server 上打开一个新的网络会话并完成 initialize 握手
+func newSessionClient(t *testing.T, server *MCPServer) *testClient {
+	t.Helper()
+	out := &syncBuffer{}
+	c := &testClient{t: t, server: server, sess: server.openSession(&lineSink{writer: out}), out: out}
+	c.initialize(nil)
+	return c
+}
+
+func (c *testClient) initialize(extra map[string]any) {
+	c.t.Helper()
+	params := map[string]any{
+		"protocolVersion": "2025-06-18",
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "test", "version": "1"},
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	if resp := c.request("initialize", params); resp.Error != nil {
+		c.t.Fatalf("initialize failed: %+v", resp.Error)
+	}
+	c.notify("notifications/initialized", nil)
+	c.out.drain()
+}
+
+// send 把 message 交给服务器处理并返回服务器写出的消息
+func (c *testClient) send(message any) [][]byte {
+	c.t.Helper()
+	data, err := json.Marshal(message)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.server.processMessage(c.sess, data)
+	return c.out.drain()
+}
+
+// request 发送请求并返回对应的响应
+func (c *testClient) request(method string, params any) ResponseMessage {
+	c.t.Helper()
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	for _, line := range c.send(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params}) {
+		var resp ResponseMessage
+		if json.Unmarshal(line, &resp) == nil && resp.ID != nil && string(*resp.ID) == id {
+			return resp
+		}
+	}
+	c.t.Fatalf("no response to %s request %s", method, id)
+	return ResponseMessage{}
+}
+
+func (c *testClient) notify(method string, params any) [][]byte {
+	c.t.Helper()
+	message := map[string]any{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	return c.send(message)
+}
+
+// callTool 发送 tools/call，成功时解码结果
+func (c *testClient) callTool(name string, arguments any) (*tools.ExecuteToolResult, *ErrorObject) {
+	c.t.Helper()
+	params := map[string]any{"name": name}
+	if arguments != nil {
+		params["arguments"] = arguments
+	}
+	resp := c.request("tools/call", params)
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StructuredContent any            `json:"structuredContent"`
+		IsError           bool           `json:"isError"`
+		Meta              map[string]any `json:"_meta"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		c.t.Fatalf("invalid tools/call result %s: %v", resp.Result, err)
+	}
+	decoded := &tools.ExecuteToolResult{StructuredContent: result.StructuredContent, IsError: result.IsError, Meta: result.Meta}
+	for _, content := range result.Content {
+		decoded.Content = append(decoded.Content, tools.NewTextContent(content.Text))
+	}
+	return decoded, nil
+}
+
+// resultText 返回结果中第一个文本内容块
+func resultText(result *tools.ExecuteToolResult) string {
+	if len(result.Content) == 0 {
+		return ""
+	}
+	text, _ := result.Content[0].(*tools.TextContent)
+	if text == nil {
+		return ""
+	}
+	return text.Text
+}
+
+var echoSchema = tools.ToolParameters{
+	Type: "object",
+	Properties: map[string]tools.ToolParameterProperties{
+		"text": {Type: "string"},
+	},
+	Required: []string{"text"},
+}
+
+func TestExecuteToolProtocolErrors(t *testing.T) {
+	c := newTestClient(t)
+	c.server.Registry().Register(tools.ToolDefinition{Name: "echo", InputSchema: echoSchema}, func(ctx context.Context, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+		t.Error("tool called with arguments that failed validation")
+		return tools.NewTextResult(""), nil
+	})
+
+	tests := []struct {
+		name      string
+		tool      string
+		arguments any
+		wantCode  int
+	}{
+		{name: "unknown tool", tool: "missing", arguments: map[string]any{}, wantCode: InvalidParamsCode},
+		{name: "missing required argument", tool: "echo", arguments: map[string]any{}, wantCode: InvalidParamsCode},
+		{name: "omitted arguments", tool: "echo", wantCode: InvalidParamsCode},
+		{name: "wrong argument type", tool: "echo", arguments: map[string]any{"text": 1}, wantCode: InvalidParamsCode},
+		{name: "arguments not an object", tool: "echo", arguments: []any{"text"}, wantCode: InvalidParamsCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, rpcErr := c.callTool(tt.tool, tt.arguments)
+			if rpcErr == nil {
+				t.Fatalf("got result %+v, want JSON-RPC error %d", result, tt.wantCode)
+			}
+			if rpcErr.Code != tt.wantCode {
+				t.Errorf("code = %d (%s), want %d", rpcErr.Code, rpcErr.Message, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestExecuteToolNotInitialized(t *testing.T) {
+	out := &syncBuffer{}
+	server := NewMCPServer(nil, out, nil)
+	c := &testClient{t: t, server: server, sess: server.stdio, out: out}
+	if _, rpcErr := c.callTool("caculator", map[string]any{"operation": "add", "num1": 1, "num2": 2}); rpcErr == nil || rpcErr.Code != InternalErrorCode {
+		t.Errorf("got %+v, want InternalError before initialization", rpcErr)
+	}
+}
+
+func TestExecuteToolNilResult(t *testing.T) {
+	c := newTestClient(t)
+	c.server.Registry().Register(tools.ToolDefinition{Name: "empty", InputSchema: tools.ToolParameters{Type: "object"}}, func(ctx context.Context, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+		return nil, nil
+	})
+
+	result, rpcErr := c.callTool("empty", nil)
+	if rpcErr != nil {
+		t.Fatalf("got JSON-RPC error %+v, want an error result", rpcErr)
+	}
+	if !result.IsError || resultText(result) != "tool returned no result" {
+		t.Errorf("result = %+v, want an error result", result)
+	}
+}
+
+func TestExecuteToolReceivesValidatedArguments(t *testing.T) {
+	c := newTestClient(t)
+	var got map[string]any
+	c.server.Registry().Register(tools.ToolDefinition{Name: "noargs", InputSchema: tools.ToolParameters{Type: "object"}}, func(ctx context.Context, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+		got = inputs
+		return tools.NewTextResult("ok"), nil
+	})
+
+	if _, rpcErr := c.callTool("noargs", nil); rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if got == nil {
+		t.Error("tool received nil arguments instead of the validated empty object")
+	}
+}
+
+func TestExecuteToolErrorResults(t *testing.T) {
+	c := newTestClient(t)
+	registry := c.server.Registry()
+	registry.Register(tools.ToolDefinition{Name: "failing", InputSchema: tools.ToolParameters{Type: "object"}}, func(ctx context.Context, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+		return nil, errors.New("upstream unavailable")
+	})
+	registry.Register(tools.ToolDefinition{Name: "panicking", InputSchema: tools.ToolParameters{Type: "object"}}, func(ctx context.Context, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+		panic("boom")
+	})
+	registry.Register(tools.ToolDefinition{Name: "limited", InputSchema: tools.ToolParameters{Type: "object"}}, func(ctx context.Context, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+		return tools.NewTextResult("ok"), nil
+	})
+	if err := registry.SetRateLimit("limited", tools.RateLimit{Rate: 0.5, Burst: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if result, rpcErr := c.callTool("limited", nil); rpcErr != nil || result.IsError {
+		t.Fatalf("first call to limited: %+v, %+v", result, rpcErr)
+	}
+
+	tests := []struct {
+		name       string
+		tool       string
+		wantText   string
+		retryAfter any // 期望的 _meta.retryAfterSeconds，nil 表示没有 _meta
+	}{
+		{name: "tool error", tool: "failing", wantText: "upstream unavailable"},
+		{name: "panic", tool: "panicking", wantText: "tool 'panicking' panicked: boom"},
+		{name: "rate limited", tool: "limited", wantText: "rate limit exceeded for tool 'limited'", retryAfter: float64(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, rpcErr := c.callTool(tt.tool, nil)
+			if rpcErr != nil {
+				t.Fatalf("got JSON-RPC error %+v, want an isError result", rpcErr)
+			}
+			if !result.IsError {
+				t.Fatalf("isError = false: %+v", result)
+			}
+			if text := resultText(result); !strings.Contains(text, tt.wantText) {
+				t.Errorf("text = %q, want it to contain %q", text, tt.wantText)
+			}
+			if tt.retryAfter == nil {
+				if result.Meta != nil {
+					t.Errorf("_meta = %v, want none", result.Meta)
+				}
+				return
+			}
+			if got := result.Meta["retryAfterSeconds"]; got != tt.retryAfter {
+				t.Errorf("_meta.retryAfterSeconds = %v, want %v", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestExecuteToolOutputSchema(t *testing.T) {
+	outputSchema := &tools.ToolParameters{
+		Type: "object",
+		Properties: map[string]tools.ToolParameterProperties{
+			"temp_c": {Type: "number"},
+		},
+		Required: []string{"temp_c"},
+	}
+
+	tests := []struct {
+		name     string
+		result   *tools.ExecuteToolResult
+		wantCode int // 0 表示期望成功
+	}{
+		{name: "valid structured content", result: &tools.ExecuteToolResult{StructuredContent: map[string]any{"temp_c": 21.5}}},
+		{name: "missing required field", result: &tools.ExecuteToolResult{StructuredContent: map[string]any{}}, wantCode: InternalErrorCode},
+		{name: "wrong field type", result: &tools.ExecuteToolResult{StructuredContent: map[string]any{"temp_c": "warm"}}, wantCode: InternalErrorCode},
+		{name: "no structured content", result: tools.NewTextResult("21.5"), wantCode: InternalErrorCode},
+		{name: "error results are not validated", result: tools.NewErrorResult(errors.New("failed"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t)
+			def := tools.ToolDefinition{Name: "weather", InputSchema: tools.ToolParameters{Type: "object"}, OutputSchema: outputSchema}
+			c.server.Registry().Register(def, func(ctx context.Context, inputs map[string]any) (*tools.ExecuteToolResult, error) {
+				return tt.result, nil
+			})
+
+			result, rpcErr := c.callTool("weather", nil)
+			if tt.wantCode == 0 {
+				if rpcErr != nil {
+					t.Fatalf("got JSON-RPC error %+v", rpcErr)
+				}
+				if result.IsError != tt.result.IsError {
+					t.Errorf("isError = %v, want %v", result.IsError, tt.result.IsError)
+				}
+				return
+			}
+			if rpcErr == nil || rpcErr.Code != tt.wantCode {
+				t.Errorf("got (%+v, %+v), want JSON-RPC error %d", result, rpcErr, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestToolListChangedNotification(t *testing.T) {
+	c := newTestClient(t)
+	registry := c.server.Registry()
+
+	tests := []struct {
+		name   string
+		change func()
+		want   int
+	}{
+		{name: "register", change: func() { registry.Register(tools.ToolDefinition{Name: "added"}, nil) }, want: 1},
+		{name: "unregister", change: func() { registry.Unregister("added") }, want: 1},
+		{name: "batch", change: func() {
+			registry.Batch(func() {
+				registry.Register(tools.ToolDefinition{Name: "one"}, nil)
+				registry.Register(tools.ToolDefinition{Name: "two"}, nil)
+				registry.Unregister("one")
+			})
+		}, want: 1},
+		{name: "no change", change: func() { registry.Unregister("missing") }, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			got := 0
+			for _, line := range c.out.drain() {
+				var notif NotificationMessage
+				if json.Unmarshal(line, &notif) == nil && notif.Method == "notifications/tools/list_changed" {
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("got %d list_changed notifications, want %d", got, tt.want)
+			}
+		})
+	}
+}
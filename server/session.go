@@ -0,0 +1,221 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stdioSessionID 是 stdio 传输上唯一会话的 ID
+const stdioSessionID = "stdio"
+
+// DefaultSessionIdleTimeout 是网络会话在没有任何请求后被关闭的默认时限
+const DefaultSessionIdleTimeout = 30 * time.Minute
+
+// messageSink 是会话向客户端发送消息的出口，不同的传输方式有不同的实现
+type messageSink interface {
+	Send(message []byte) error
+}
+
+// session 代表一个客户端会话的状态。stdio 传输只有一个会话，
+// 网络传输上每个连接的客户端对应一个会话
+type session struct {
+	id         string
+	sink       messageSink
+	opened     time.Time
+	lastActive atomic.Int64 // 最近一次请求的时间 (UnixNano)
+	streams    atomic.Int32 // 打开的 SSE 流数量
+
+	mu              sync.RWMutex
+	initialized     bool
+	clientInfo      *ClientInfo
+	protocolVersion string
+	logLevel        *slog.Level // 通过 logging/setLevel 订阅的最低日志级别，为 nil 表示未订阅
+	trace           TraceValue  // 为空时使用服务器的默认 trace 级别
+}
+
+func newSession(id string, sink messageSink) *session {
+	sess := &session{id: id, sink: sink, opened: time.Now()}
+	sess.touch()
+	return sess
+}
+
+// touch 记录会话的一次活动
+func (sess *session) touch() {
+	sess.lastActive.Store(time.Now().UnixNano())
+}
+
+// idle 判断会话在 now 时是否已空闲超过 timeout
+func (sess *session) idle(now time.Time, timeout time.Duration) bool {
+	if sess.streams.Load() > 0 {
+		return false
+	}
+	return now.Sub(time.Unix(0, sess.lastActive.Load())) > timeout
+}
+
+// openSession 创建并登记一个新的会话
+func (s *MCPServer) openSession(sink messageSink) *session {
+	sess := newSession(newSessionID(), sink)
+
+	s.sessionsMu.Lock()
+	s.sessions[sess.id] = sess
+	s.sessionsMu.Unlock()
+
+	s.logger.Info("Session opened", "session", sessionDigest(sess.id))
+	return sess
+}
+
+func (s *MCPServer) lookupSession(id string) (*session, bool) {
+	if id == "" || id == stdioSessionID {
+		return nil, false
+	}
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+
+	sess, ok := s.sessions[id]
+	if ok {
+		sess.touch()
+	}
+	return sess, ok
+}
+
+// sessionByDigest 按会话 ID 的摘要查找会话，日志中只记录摘要，转发日志时据此找到目标会话
+func (s *MCPServer) sessionByDigest(digest string) *session {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+
+	for _, sess := range s.sessions {
+		if sessionDigest(sess.id) == digest {
+			return sess
+		}
+	}
+	return nil
+}
+
+// closeSession 移除会话并释放与之相关的状态
+func (s *MCPServer) closeSession(id string) {
+	s.sessionsMu.Lock()
+	sess, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.sessionsMu.Unlock()
+	if !ok {
+		return
+	}
+
+	if closer, ok := sess.sink.(interface{ close() }); ok {
+		closer.close()
+	}
+	s.tools.ForgetSession(id)
+	s.updateLogSubscriptions()
+	s.logger.Info("Session closed", "session", sessionDigest(id))
+}
+
+// SetSessionIdleTimeout 设置网络会话的空闲时限，非正数表示使用 DefaultSessionIdleTimeout
+func (s *MCPServer) SetSessionIdleTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultSessionIdleTimeout
+	}
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.sessionIdleTimeout = timeout
+}
+
+// expireIdleSessions 定期关闭空闲超时的网络会话，直到服务器关闭。
+// 客户端可能不发送 DELETE 就离开，不清理的话这些会话会一直占用内存
+func (s *MCPServer) expireIdleSessions() {
+	for {
+		// 检查间隔取空闲时限的一半，最长一分钟
+		timer := time.NewTimer(min(s.idleTimeout()/2, time.Minute))
+		select {
+		case now := <-timer.C:
+			s.closeIdleSessions(now)
+		case <-s.ShutdownSignal:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (s *MCPServer) idleTimeout() time.Duration {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.sessionIdleTimeout
+}
+
+// closeIdleSessions 关闭在 now 时已空闲超时的网络会话
+func (s *MCPServer) closeIdleSessions(now time.Time) {
+	timeout := s.idleTimeout()
+	for _, sess := range s.sessionList() {
+		if sess.id == stdioSessionID || !sess.idle(now, timeout) {
+			continue
+		}
+		s.logger.Info("Session expired", "session", sessionDigest(sess.id), "idleTimeout", timeout.String())
+		s.closeSession(sess.id)
+	}
+}
+
+// sessionList 返回当前所有会话的快照
+func (s *MCPServer) sessionList() []*session {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (sess *session) isInitialized() bool {
+	sess.mu.RLock()
+	defer sess.mu.RUnlock()
+	return sess.initialized
+}
+
+func (sess *session) setInitialized() {
+	sess.mu.Lock()
+	sess.initialized = true
+	sess.mu.Unlock()
+}
+
+// writeMessage 通过会话的传输方式发送一条消息
+func (sess *session) writeMessage(message []byte) error {
+	return sess.sink.Send(message)
+}
+
+// lineSink 把消息以换行分隔写入 io.Writer，用于 stdio 传输
+type lineSink struct {
+	mu     sync.Mutex // 保证响应和通知不会交错写入
+	writer io.Writer
+}
+
+func (l *lineSink) Send(message []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 直接写入消息体
+	if _, err := l.writer.Write(message); err != nil {
+		return err
+	}
+	// 在消息体后写入换行符
+	if _, err := l.writer.Write([]byte("\n")); err != nil {
+		return err
+	}
+
+	if flusher, ok := l.writer.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
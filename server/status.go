@@ -0,0 +1,145 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/n8sPxD/mcp-server-demo/tools"
+)
+
+// 健康检查端点随 HTTP 传输一起提供，状态端点见 DebugHandler
+const (
+	healthEndpoint = "/healthz"
+	statusEndpoint = "/debug/status"
+)
+
+// Status 是 /debug/status 返回的服务器状态
+type Status struct {
+	Server        ServerInfo                      `json:"server"`
+	StartedAt     time.Time                       `json:"startedAt"`
+	UptimeSeconds float64                         `json:"uptimeSeconds"`
+	Sessions      []SessionStatus                 `json:"sessions"`
+	Tools         []string                        `json:"tools"`
+	Upstreams     map[string]tools.UpstreamHealth `json:"upstreams"`
+}
+
+// SessionStatus 描述一个会话
+type SessionStatus struct {
+	ID              string      `json:"id"`        // 会话 ID 的摘要，见 sessionDigest
+	Transport       string      `json:"transport"` // "stdio" 或 "http"
+	OpenedAt        time.Time   `json:"openedAt"`
+	Initialized     bool        `json:"initialized"`
+	Client          *ClientInfo `json:"client,omitempty"`
+	ProtocolVersion string      `json:"protocolVersion,omitempty"`
+	LogLevel        string      `json:"logLevel,omitempty"` // 通过 logging/setLevel 订阅的级别
+	Trace           TraceValue  `json:"trace"`
+}
+
+// Status 返回服务器当前的状态。没有客户端初始化过的 stdio 会话不算活动会话，不会列出
+func (s *MCPServer) Status() Status {
+	s.settingsMu.RLock()
+	serverInfo := s.serverInfo
+	s.settingsMu.RUnlock()
+
+	status := Status{
+		Server:        serverInfo,
+		StartedAt:     s.startedAt,
+		UptimeSeconds: time.Since(s.startedAt).Seconds(),
+		Sessions:      []SessionStatus{},
+		Tools:         []string{},
+		Upstreams:     tools.UpstreamStatus(),
+	}
+
+	for _, sess := range s.sessionList() {
+		if sess == s.stdio && !sess.isInitialized() {
+			continue
+		}
+		sessionStatus := SessionStatus{
+			ID:        sessionDigest(sess.id),
+			Transport: "http",
+			OpenedAt:  sess.opened,
+			Trace:     s.sessionTrace(sess),
+		}
+		if sess == s.stdio {
+			sessionStatus.Transport = "stdio"
+		}
+		if level, ok := sess.subscribedLogLevel(); ok {
+			sessionStatus.LogLevel = string(loggingLevelOf(level))
+		}
+		sess.mu.RLock()
+		sessionStatus.Initialized = sess.initialized
+		sessionStatus.Client = sess.clientInfo
+		sessionStatus.ProtocolVersion = sess.protocolVersion
+		sess.mu.RUnlock()
+		status.Sessions = append(status.Sessions, sessionStatus)
+	}
+	sort.Slice(status.Sessions, func(i, j int) bool {
+		return status.Sessions[i].OpenedAt.Before(status.Sessions[j].OpenedAt)
+	})
+
+	for _, def := range s.tools.List() {
+		status.Tools = append(status.Tools, def.Name)
+	}
+	return status
+}
+
+// handleHealth 处理 /healthz：服务器运行中返回 200，开始关闭后返回 503，供编排系统做存活和就绪探测
+func (s *MCPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := struct {
+		Status        string  `json:"status"`
+		UptimeSeconds float64 `json:"uptimeSeconds"`
+	}{Status: "ok", UptimeSeconds: time.Since(s.startedAt).Seconds()}
+
+	code := http.StatusOK
+	select {
+	case <-s.ShutdownSignal:
+		health.Status = "shutting_down"
+		code = http.StatusServiceUnavailable
+	default:
+	}
+	writeJSON(w, code, health)
+}
+
+// sessionDigest 返回会话 ID 的 SHA-256 前缀。Mcp-Session-Id 是 HTTP 会话唯一的凭据，
+// 状态、日志、审计日志和追踪中只给出用于区分会话的摘要，不暴露 ID 本身
+func sessionDigest(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:6])
+}
+
+// DebugHandler 返回调试端点的处理器：
+//
+//	GET /debug/status  服务器状态：运行时间、会话、工具和上游服务的健康状况
+func (s *MCPServer) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+statusEndpoint, s.handleStatus)
+	return mux
+}
+
+// ListenAndServeDebug 在 addr 上提供 DebugHandler。状态中有会话和客户端信息，addr 应当是回环地址
+func (s *MCPServer) ListenAndServeDebug(addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.DebugHandler(),
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+	}
+	return srv.ListenAndServe()
+}
+
+// handleStatus 处理 /debug/status，返回 Status
+func (s *MCPServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Status())
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusHidesSessionIDs(t *testing.T) {
+	s := NewMCPServer(nil, io.Discard, nil)
+	rec := httptest.NewRecorder()
+	s.HTTPHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, httpEndpoint, strings.NewReader(initializeRequest)))
+	id := rec.Header().Get(SessionIDHeader)
+
+	rec = httptest.NewRecorder()
+	s.HTTPHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, statusEndpoint, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status endpoint on the transport: got %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, statusEndpoint, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, id) {
+		t.Error("status exposes the session ID")
+	}
+	if !strings.Contains(body, sessionDigest(id)) {
+		t.Errorf("status does not list the session digest: %s", body)
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// TraceValue 控制会话收发的消息如何记录到日志，取值与 LSP 的 trace 设置一致
+type TraceValue string
+
+const (
+	TraceOff      TraceValue = "off"      // 不记录消息
+	TraceMessages TraceValue = "messages" // 只记录消息摘要：方向、类型、方法、ID 和大小
+	TraceVerbose  TraceValue = "verbose"  // 在摘要之外记录完整的消息内容
+)
+
+// Valid 判断 t 是否是支持的取值
+func (t TraceValue) Valid() bool {
+	switch t {
+	case TraceOff, TraceMessages, TraceVerbose:
+		return true
+	}
+	return false
+}
+
+// SetTraceParams 是 $/setTrace 通知的参数
+type SetTraceParams struct {
+	Value TraceValue `json:"value"`
+}
+
+// SetDefaultTrace 设置 initialize 中没有指定 trace 的会话使用的 trace 级别，无效的取值按 TraceOff 处理
+func (s *MCPServer) SetDefaultTrace(trace TraceValue) {
+	if !trace.Valid() {
+		trace = TraceOff
+	}
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.defaultTrace = trace
+}
+
+// sessionTrace 返回会话当前的 trace 级别
+func (s *MCPServer) sessionTrace(sess *session) TraceValue {
+	sess.mu.RLock()
+	trace := sess.trace
+	sess.mu.RUnlock()
+	if trace != "" {
+		return trace
+	}
+
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.defaultTrace
+}
+
+// setSessionTrace 修改会话的 trace 级别，无效的取值会被忽略并返回 false
+func (s *MCPServer) setSessionTrace(ctx context.Context, sess *session, trace TraceValue) bool {
+	if !trace.Valid() {
+		s.logger.WarnContext(ctx, "Ignoring unknown trace value", "trace", string(trace))
+		return false
+	}
+	sess.mu.Lock()
+	sess.trace = trace
+	sess.mu.Unlock()
+	s.logger.InfoContext(ctx, "Session trace set", "trace", string(trace))
+	return true
+}
+
+// traceMessage 按会话的 trace 级别记录一条收到或发出的消息，ctx 应带有会话属性。
+// 这些记录只写入服务器日志，不会通过 notifications/message 回显给客户端
+func (s *MCPServer) traceMessage(ctx context.Context, sess *session, direction string, message []byte) {
+	trace := s.sessionTrace(sess)
+	if trace == TraceOff {
+		return
+	}
+
+	var probe struct {
+		ID     *json.RawMessage `json:"id"`
+		Method string           `json:"method"`
+		Error  *ErrorObject     `json:"error"`
+	}
+	_ = json.Unmarshal(message, &probe)
+
+	attrs := []any{"direction", direction, "size", len(message)}
+	switch {
+	case probe.Method != "" && probe.ID != nil:
+		attrs = append(attrs, "kind", "request", "method", probe.Method, "id", string(*probe.ID))
+	case probe.Method != "":
+		attrs = append(attrs, "kind", "notification", "method", probe.Method)
+	case probe.ID != nil:
+		attrs = append(attrs, "kind", "response", "id", string(*probe.ID))
+		if probe.Error != nil {
+			attrs = append(attrs, "errorCode", probe.Error.Code)
+		}
+	default:
+		attrs = append(attrs, "kind", "invalid")
+	}
+	if trace == TraceVerbose {
+		if json.Valid(message) {
+			attrs = append(attrs, "payload", json.RawMessage(message))
+		} else {
+			attrs = append(attrs, "payload", string(message))
+		}
+	}
+
+	s.logger.Log(withoutForwarding(ctx), slog.LevelInfo, "Trace message", attrs...)
+}
+
+// handleSetTrace 处理 $/setTrace 通知，在会话中途修改 trace 级别
+func (s *MCPServer) handleSetTrace(ctx context.Context, sess *session, notif NotificationMessage) {
+	var params SetTraceParams
+	if err := json.Unmarshal(notif.Params, &params); err != nil {
+		s.logger.WarnContext(ctx, "Invalid params for $/setTrace", "error", err)
+		return
+	}
+	s.setSessionTrace(ctx, sess, params.Value)
+}
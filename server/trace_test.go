@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// traceRecord 是日志中的一条 "Trace message" 记录
+type traceRecord struct {
+	Direction string          `json:"direction"`
+	Kind      string          `json:"kind"`
+	Method    string          `json:"method"`
+	ID        string          `json:"id"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// newTracingClient 创建把日志写入 logs 的服务器，并以 trace 完成 initialize 握手
+func newTracingClient(t *testing.T, trace TraceValue, defaultTrace TraceValue) (*testClient, *syncBuffer) {
+	t.Helper()
+	logs := &syncBuffer{}
+	out := &syncBuffer{}
+	server := NewMCPServer(nil, out, slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	server.SetDefaultTrace(defaultTrace)
+	c := &testClient{t: t, server: server, sess: server.stdio, out: out}
+	var extra map[string]any
+	if trace != "" {
+		extra = map[string]any{"trace": trace}
+	}
+	c.initialize(extra)
+	logs.drain()
+	return c, logs
+}
+
+func traceRecords(logs *syncBuffer) []traceRecord {
+	var records []traceRecord
+	for _, line := range logs.drain() {
+		var record struct {
+			Msg string `json:"msg"`
+			traceRecord
+		}
+		if json.Unmarshal(line, &record) == nil && record.Msg == "Trace message" {
+			records = append(records, record.traceRecord)
+		}
+	}
+	return records
+}
+
+func TestTraceLevels(t *testing.T) {
+	tests := []struct {
+		name         string
+		trace        TraceValue // initialize 中的 trace
+		defaultTrace TraceValue
+		wantRecords  int
+		wantPayload  bool
+	}{
+		{name: "off", trace: TraceOff, defaultTrace: TraceVerbose},
+		{name: "messages", trace: TraceMessages, wantRecords: 2},
+		{name: "verbose", trace: TraceVerbose, wantRecords: 2, wantPayload: true},
+		{name: "server default", defaultTrace: TraceMessages, wantRecords: 2},
+		{name: "invalid value keeps the default", trace: "loud", defaultTrace: TraceMessages, wantRecords: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, logs := newTracingClient(t, tt.trace, tt.defaultTrace)
+			c.request("tools/list", nil)
+
+			records := traceRecords(logs)
+			if len(records) != tt.wantRecords {
+				t.Fatalf("got %d trace records, want %d: %+v", len(records), tt.wantRecords, records)
+			}
+			if tt.wantRecords == 0 {
+				return
+			}
+			received, sent := records[0], records[1]
+			if received.Direction != DirectionReceived || received.Kind != "request" || received.Method != "tools/list" || received.ID == "" {
+				t.Errorf("received record = %+v", received)
+			}
+			if sent.Direction != DirectionSent || sent.Kind != "response" || sent.ID != received.ID {
+				t.Errorf("sent record = %+v", sent)
+			}
+			for _, record := range records {
+				if (record.Payload != nil) != tt.wantPayload {
+					t.Errorf("payload = %s, want payload %v", record.Payload, tt.wantPayload)
+				}
+			}
+		})
+	}
+}
+
+func TestSetTrace(t *testing.T) {
+	c, logs := newTracingClient(t, TraceOff, TraceOff)
+
+	tests := []struct {
+		value       TraceValue
+		wantRecords int
+		wantPayload bool
+	}{
+		{value: TraceVerbose, wantRecords: 2, wantPayload: true},
+		{value: TraceMessages, wantRecords: 2},
+		{value: "unknown", wantRecords: 2}, // 无效的取值被忽略
+		{value: TraceOff},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.value), func(t *testing.T) {
+			c.notify("$/setTrace", map[string]any{"value": tt.value})
+			logs.drain()
+
+			c.request("tools/list", nil)
+			records := traceRecords(logs)
+			if len(records) != tt.wantRecords {
+				t.Fatalf("got %d trace records, want %d", len(records), tt.wantRecords)
+			}
+			for _, record := range records {
+				if (record.Payload != nil) != tt.wantPayload {
+					t.Errorf("payload = %s, want payload %v", record.Payload, tt.wantPayload)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/n8sPxD/mcp-server-demo/logging"
+	"github.com/n8sPxD/mcp-server-demo/tracing"
+)
+
+// requestMeta 是请求参数中 _meta 字段里与追踪有关的部分，宿主可以借此把调用链传给服务器
+type requestMeta struct {
+	Meta struct {
+		Traceparent string `json:"traceparent"`
+		Tracestate  string `json:"tracestate"`
+	} `json:"_meta"`
+}
+
+// startRequestSpan 为请求创建 server span。参数的 _meta 中带有 traceparent 时，span 加入宿主的调用链。
+// 追踪开启时，处理过程中的日志会带上 traceId 和 spanId
+func startRequestSpan(ctx context.Context, sess *session, req RequestMessage) (context.Context, *tracing.Span) {
+	var meta requestMeta
+	if len(req.Params) > 0 && json.Unmarshal(req.Params, &meta) == nil {
+		if parent, ok := tracing.ParseTraceparent(meta.Meta.Traceparent, meta.Meta.Tracestate); ok {
+			ctx = tracing.ContextWithRemoteParent(ctx, parent)
+		}
+	}
+
+	ctx, span := tracing.Start(ctx, req.Method, tracing.KindServer,
+		tracing.Attr("rpc.system", "jsonrpc"),
+		tracing.Attr("rpc.method", req.Method),
+		tracing.Attr("rpc.jsonrpc.request_id", string(*req.ID)),
+		tracing.Attr("mcp.session.id", sessionDigest(sess.id)), // 会话 ID 是会话唯一的凭据，只导出摘要
+	)
+	if span != nil {
+		sc := span.SpanContext()
+		ctx = logging.WithAttrs(ctx, slog.String("traceId", sc.TraceID.String()), slog.String("spanId", sc.SpanID.String()))
+	}
+	return ctx, span
+}
+
+// recordErrorResponse 把 ctx 中的请求 span 标记为以 JSON-RPC 错误结束
+func recordErrorResponse(ctx context.Context, err *ErrorObject) {
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttributes(tracing.Attr("rpc.jsonrpc.error_code", err.Code))
+	span.SetStatus(tracing.StatusError, err.Message)
+}
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultCacheMaxEntries 是未指定容量时每个工具缓存的最大条目数
+const DefaultCacheMaxEntries = 128
+
+// CacheOptions 配置一个工具的结果缓存
+type CacheOptions struct {
+	TTL        time.Duration // 缓存条目的有效期
+	MaxEntries int           // 超过后按 LRU 淘汰，为 0 时使用 DefaultCacheMaxEntries
+}
+
+// CacheStats 是工具缓存的统计信息
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+}
+
+type cacheEntry struct {
+	key       string
+	result    *ExecuteToolResult
+	expiresAt time.Time
+}
+
+// resultCache 是带 TTL 的 LRU 结果缓存
+type resultCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	ll         *list.List // 最近使用的条目在前
+	items      map[string]*list.Element
+	stats      CacheStats
+}
+
+func newResultCache(opts CacheOptions) *resultCache {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultCacheMaxEntries
+	}
+	return &resultCache{
+		ttl:        opts.TTL,
+		maxEntries: opts.MaxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// cacheKey 由工具名和规范化后的参数组成。encoding/json 对 map 的键排序，
+// 数字统一为 float64，因此键顺序或数字写法不同的等价参数会得到相同的键
+func cacheKey(name string, inputs map[string]any) (string, error) {
+	canonical, err := json.Marshal(inputs)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to canonicalize tool arguments")
+	}
+	return name + "\x00" + string(canonical), nil
+}
+
+func (c *resultCache) get(key string) (*ExecuteToolResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	c.stats.Hits++
+	return entry.result, true
+}
+
+func (c *resultCache) put(key string, result *ExecuteToolResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.result = result
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, result: result, expiresAt: expiresAt})
+	for c.ll.Len() > c.maxEntries {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+func (c *resultCache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.ll.Len()
+	return stats
+}
+
+// cached 在命中时直接返回缓存结果，只缓存成功且非 isError 的结果
+func (c *resultCache) cached(next Handler) Handler {
+	return func(ctx context.Context, req *CallRequest) (*ExecuteToolResult, error) {
+		key, err := cacheKey(req.ToolName, req.Inputs)
+		if err != nil {
+			return next(ctx, req)
+		}
+		if result, ok := c.get(key); ok {
+			cacheLookupsTotal.WithLabelValues(req.ToolName, "hit").Inc()
+			return result, nil
+		}
+		cacheLookupsTotal.WithLabelValues(req.ToolName, "miss").Inc()
+
+		result, err := next(ctx, req)
+		if err == nil && result != nil && !result.IsError {
+			c.put(key, result)
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResultCacheLRU(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		puts       []string
+		gets       []string // 在 put 之后依次读取，用于调整 LRU 顺序
+		more       []string // 读取之后再写入的键
+		present    []string
+		evicted    []string
+	}{
+		{
+			name:       "oldest entry is evicted",
+			maxEntries: 2,
+			puts:       []string{"a", "b", "c"},
+			present:    []string{"b", "c"},
+			evicted:    []string{"a"},
+		},
+		{
+			name:       "reading an entry protects it from eviction",
+			maxEntries: 2,
+			puts:       []string{"a", "b"},
+			gets:       []string{"a"},
+			more:       []string{"c"},
+			present:    []string{"a", "c"},
+			evicted:    []string{"b"},
+		},
+		{
+			name:       "overwriting does not grow the cache",
+			maxEntries: 2,
+			puts:       []string{"a", "b", "a", "a"},
+			present:    []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newResultCache(CacheOptions{TTL: time.Minute, MaxEntries: tt.maxEntries})
+			for _, key := range tt.puts {
+				c.put(key, NewTextResult(key))
+			}
+			for _, key := range tt.gets {
+				c.get(key)
+			}
+			for _, key := range tt.more {
+				c.put(key, NewTextResult(key))
+			}
+			for _, key := range tt.present {
+				if _, ok := c.get(key); !ok {
+					t.Errorf("%s should be cached", key)
+				}
+			}
+			for _, key := range tt.evicted {
+				if _, ok := c.get(key); ok {
+					t.Errorf("%s should have been evicted", key)
+				}
+			}
+			if stats := c.snapshot(); stats.Evictions != int64(len(tt.evicted)) {
+				t.Errorf("evictions = %d, want %d", stats.Evictions, len(tt.evicted))
+			}
+		})
+	}
+}
+
+func TestResultCacheTTL(t *testing.T) {
+	c := newResultCache(CacheOptions{TTL: 20 * time.Millisecond})
+	c.put("a", NewTextResult("a"))
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("entry should be cached before it expires")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := c.get("a"); ok {
+		t.Fatal("entry should expire after the TTL")
+	}
+	if stats := c.snapshot(); stats.Entries != 0 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 0 entries, 1 hit and 1 miss", stats)
+	}
+}
+
+func TestCacheKeyCanonical(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  map[string]any
+		equal bool
+	}{
+		{name: "key order", a: map[string]any{"x": 1.0, "y": "z"}, b: map[string]any{"y": "z", "x": 1.0}, equal: true},
+		{name: "different values", a: map[string]any{"x": 1.0}, b: map[string]any{"x": 2.0}, equal: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyA, err := cacheKey("tool", tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyB, err := cacheKey("tool", tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (keyA == keyB) != tt.equal {
+				t.Errorf("keys %q and %q: equal = %v, want %v", keyA, keyB, keyA == keyB, tt.equal)
+			}
+		})
+	}
+}
+
+func TestRegistryCache(t *testing.T) {
+	calls := 0
+	r := NewRegistry()
+	r.Register(ToolDefinition{Name: "count", Annotations: &ToolAnnotations{IdempotentHint: boolPtr(true)}},
+		func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+			calls++
+			return NewTextResult("ok"), nil
+		})
+	if err := r.EnableCache("count", CacheOptions{TTL: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	r.SetAliases(map[string]string{"tally": "count"})
+
+	// 别名和目标共享同一个缓存
+	for _, name := range []string{"count", "count", "tally"} {
+		if _, err := r.Call(context.Background(), name, map[string]any{"n": 1.0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("tool ran %d times, want 1", calls)
+	}
+	if stats := r.CacheStats()["count"]; stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 2 hits and 1 miss", stats)
+	}
+}
+
+func TestEnableCacheRequiresIdempotent(t *testing.T) {
+	r := NewRegistry()
+	r.Register(ToolDefinition{Name: "write"}, func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		return NewTextResult("ok"), nil
+	})
+	if err := r.EnableCache("write", CacheOptions{TTL: time.Minute}); err == nil {
+		t.Fatal("caching a tool without idempotentHint should fail")
+	}
+}
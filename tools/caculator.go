@@ -26,13 +26,5 @@ func ExecuteCaculate(operation string, num1 float64, num2 float64) (*ExecuteTool
 		return nil, err
 	}
 
-	// 构建符合客户端期望的 text content block
-	textContentBlock := map[string]any{
-		"type": "text",
-		"text": fmt.Sprintf("The result of %s %f and %f is %f", operation, num1, num2, result),
-	}
-
-	return &ExecuteToolResult{
-		Content: []map[string]any{textContentBlock},
-	}, nil
+	return NewTextResult(fmt.Sprintf("The result of %s %f and %f is %f", operation, num1, num2, result)), nil
 }
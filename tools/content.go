@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// 内容块类型
+const (
+	ContentTypeText         = "text"
+	ContentTypeImage        = "image"
+	ContentTypeAudio        = "audio"
+	ContentTypeResource     = "resource"
+	ContentTypeResourceLink = "resource_link"
+)
+
+// Role 表示内容的目标受众
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Annotations 是内容块的可选注解，客户端据此决定如何使用或展示内容
+type Annotations struct {
+	Audience []Role   `json:"audience,omitempty"`
+	Priority *float64 `json:"priority,omitempty"` // 0 表示可有可无，1 表示最重要
+}
+
+// Annotated 嵌入到每个内容块中，提供 annotations 字段
+type Annotated struct {
+	Annotations *Annotations `json:"annotations,omitempty"`
+}
+
+func (a *Annotated) annotated() *Annotated {
+	return a
+}
+
+// Content 是所有内容块的公共接口
+type Content interface {
+	annotated() *Annotated
+}
+
+// ContentOption 用于在构造内容块时设置注解
+type ContentOption func(a *Annotated)
+
+// WithAudience 设置内容的目标受众
+func WithAudience(roles ...Role) ContentOption {
+	return func(a *Annotated) {
+		if a.Annotations == nil {
+			a.Annotations = &Annotations{}
+		}
+		a.Annotations.Audience = roles
+	}
+}
+
+// WithPriority 设置内容的优先级 (0 ~ 1)
+func WithPriority(priority float64) ContentOption {
+	return func(a *Annotated) {
+		if a.Annotations == nil {
+			a.Annotations = &Annotations{}
+		}
+		a.Annotations.Priority = &priority
+	}
+}
+
+func applyContentOptions(c Content, opts []ContentOption) {
+	for _, opt := range opts {
+		opt(c.annotated())
+	}
+}
+
+// TextContent 是文本内容块
+type TextContent struct {
+	Annotated
+	Type string `json:"type"` // 固定为 "text"
+	Text string `json:"text"`
+}
+
+// ImageContent 是图片内容块，Data 为 base64 编码的图片数据
+type ImageContent struct {
+	Annotated
+	Type     string `json:"type"` // 固定为 "image"
+	Data     string `json:"data"`
+	MimeType string `json:"mimeType"`
+}
+
+// AudioContent 是音频内容块，Data 为 base64 编码的音频数据
+type AudioContent struct {
+	Annotated
+	Type     string `json:"type"` // 固定为 "audio"
+	Data     string `json:"data"`
+	MimeType string `json:"mimeType"`
+}
+
+// ResourceContents 是资源的内容，Text 和 Blob (base64) 二选一
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// EmbeddedResource 是内嵌资源内容块
+type EmbeddedResource struct {
+	Annotated
+	Type     string           `json:"type"` // 固定为 "resource"
+	Resource ResourceContents `json:"resource"`
+}
+
+// ResourceLink 是指向资源的链接内容块，客户端可以按需读取
+type ResourceLink struct {
+	Annotated
+	Type        string `json:"type"` // 固定为 "resource_link"
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+func NewTextContent(text string, opts ...ContentOption) *TextContent {
+	c := &TextContent{Type: ContentTypeText, Text: text}
+	applyContentOptions(c, opts)
+	return c
+}
+
+func NewImageContent(data []byte, mimeType string, opts ...ContentOption) *ImageContent {
+	c := &ImageContent{
+		Type:     ContentTypeImage,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		MimeType: mimeType,
+	}
+	applyContentOptions(c, opts)
+	return c
+}
+
+func NewAudioContent(data []byte, mimeType string, opts ...ContentOption) *AudioContent {
+	c := &AudioContent{
+		Type:     ContentTypeAudio,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		MimeType: mimeType,
+	}
+	applyContentOptions(c, opts)
+	return c
+}
+
+func NewTextResourceContents(uri, mimeType, text string) ResourceContents {
+	return ResourceContents{URI: uri, MimeType: mimeType, Text: text}
+}
+
+func NewBlobResourceContents(uri, mimeType string, blob []byte) ResourceContents {
+	return ResourceContents{URI: uri, MimeType: mimeType, Blob: base64.StdEncoding.EncodeToString(blob)}
+}
+
+func NewEmbeddedResource(resource ResourceContents, opts ...ContentOption) *EmbeddedResource {
+	c := &EmbeddedResource{Type: ContentTypeResource, Resource: resource}
+	applyContentOptions(c, opts)
+	return c
+}
+
+func NewResourceLink(uri, name, description, mimeType string, opts ...ContentOption) *ResourceLink {
+	c := &ResourceLink{
+		Type:        ContentTypeResourceLink,
+		URI:         uri,
+		Name:        name,
+		Description: description,
+		MimeType:    mimeType,
+	}
+	applyContentOptions(c, opts)
+	return c
+}
+
+// UnmarshalContent 根据 type 字段把 JSON 解码为对应的内容块
+func UnmarshalContent(raw json.RawMessage) (Content, error) {
+	var probe struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, errors.Wrap(err, "invalid content block")
+	}
+
+	var c Content
+	switch probe.Type {
+	case ContentTypeText:
+		c = &TextContent{}
+	case ContentTypeImage:
+		c = &ImageContent{}
+	case ContentTypeAudio:
+		c = &AudioContent{}
+	case ContentTypeResource:
+		c = &EmbeddedResource{}
+	case ContentTypeResourceLink:
+		c = &ResourceLink{}
+	default:
+		return nil, fmt.Errorf("unknown content block type '%s'", probe.Type)
+	}
+	if err := json.Unmarshal(raw, c); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s content block", probe.Type)
+	}
+	return c, nil
+}
+
+// UnmarshalJSON 解码 ExecuteToolResult，把 content 数组还原为具体的内容块类型
+func (r *ExecuteToolResult) UnmarshalJSON(data []byte) error {
+	type plain ExecuteToolResult
+	var aux struct {
+		plain
+		Content []json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	*r = ExecuteToolResult(aux.plain)
+	r.Content = make([]Content, 0, len(aux.Content))
+	for _, raw := range aux.Content {
+		c, err := UnmarshalContent(raw)
+		if err != nil {
+			return err
+		}
+		r.Content = append(r.Content, c)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package tools
+
+import "context"
+
+type sessionIDKey struct{}
+
+// WithSessionID 把发起调用的会话 ID 放入 ctx，供按会话的限流等逻辑使用
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, sessionID)
+}
+
+// SessionIDFromContext 返回 ctx 中的会话 ID，没有时返回空字符串
+func SessionIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(sessionIDKey{}).(string)
+	return id
+}
@@ -1,17 +1,21 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/n8sPxD/mcp-server-demo/tracing"
 	"github.com/pkg/errors"
 )
 
-func GetWeather(location string) (*ExecuteToolResult, error) {
+func GetWeather(ctx context.Context, location string) (*ExecuteToolResult, error) {
 	if location == "" {
 		return nil, errors.Wrap(
 			errors.New("missing or invalid 'location' parameter for get_weather tool"),
@@ -20,7 +24,7 @@ func GetWeather(location string) (*ExecuteToolResult, error) {
 	}
 
 	// 获取天气信息
-	weather, err := getWeather(location)
+	weather, err := getWeather(ctx, location)
 	if err != nil {
 		return nil, err
 	}
@@ -28,21 +32,69 @@ func GetWeather(location string) (*ExecuteToolResult, error) {
 	// 将天气信息格式化为字符串
 	weatherString := fmt.Sprintf("Location: %s, Weather: %s, Temperature: %.1f°C", location, weather.Weather, weather.TempC)
 
-	// 构建符合客户端期望的 text content block
-	textContentBlock := map[string]any{
-		"type": "text",
-		"text": weatherString,
+	// 同时返回结构化输出，以及序列化后的 JSON 文本以兼容不支持 structuredContent 的客户端
+	weatherJSON, err := json.Marshal(weather)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal weather response")
 	}
 
-	// 将 text content block 包装在 Content 数组中
-	result := ExecuteToolResult{
-		Content: []map[string]any{textContentBlock},
-	}
+	return &ExecuteToolResult{
+		Content: []Content{
+			NewTextContent(weatherString),
+			NewTextContent(string(weatherJSON)),
+		},
+		StructuredContent: weather,
+	}, nil
+}
+
+// 天气服务提供方
+const (
+	WeatherProviderAuto       = ""           // 按环境变量自动选择
+	WeatherProviderGoogleMap  = "google_map" // Google Map Weather API
+	WeatherProviderWeatherAPI = "weatherapi" // weatherapi.com
+)
+
+// weatherGetterOverride 是通过配置指定的天气服务，为 nil 时按环境变量自动选择
+var weatherGetterOverride struct {
+	sync.RWMutex
+	getter WeatherGetter
+}
+
+// SetWeatherGetter 指定 get_weather 使用的天气服务，传入 nil 恢复按环境变量自动选择
+func SetWeatherGetter(getter WeatherGetter) {
+	weatherGetterOverride.Lock()
+	defer weatherGetterOverride.Unlock()
+	weatherGetterOverride.getter = getter
+}
 
-	return &result, nil
+// NewWeatherGetter 按提供方名称创建天气服务，apiKey 为空时从对应的环境变量读取。
+// provider 为 WeatherProviderAuto 时返回 nil，表示按环境变量自动选择
+func NewWeatherGetter(provider, apiKey string) (WeatherGetter, error) {
+	switch provider {
+	case WeatherProviderAuto:
+		return nil, nil
+	case WeatherProviderGoogleMap:
+		if apiKey == "" {
+			return NewGoogleMapWeatherGetter()
+		}
+		return &GoogleMapWeatherGetter{apiKey: apiKey}, nil
+	case WeatherProviderWeatherAPI:
+		if apiKey == "" {
+			return NewWeatherAPIWeatherGetter()
+		}
+		return &WeatherAPIWeatherGetter{apiKey: apiKey}, nil
+	}
+	return nil, fmt.Errorf("unknown weather provider '%s'", provider)
 }
 
-func getWeather(location string) (*CommonWeatherResponse, error) {
+func getWeather(ctx context.Context, location string) (*CommonWeatherResponse, error) {
+	weatherGetterOverride.RLock()
+	override := weatherGetterOverride.getter
+	weatherGetterOverride.RUnlock()
+	if override != nil {
+		return override.GetWeather(ctx, location)
+	}
+
 	// 通过检查os.Getenv来确定用哪一个
 	var weatherGetter WeatherGetter
 	var err error
@@ -61,11 +113,22 @@ func getWeather(location string) (*CommonWeatherResponse, error) {
 	if err != nil {
 		return nil, err
 	}
-	return weatherGetter.GetWeather(location)
+	return weatherGetter.GetWeather(ctx, location)
+}
+
+// weatherHTTPClient 用于请求外部天气服务，设置超时以防请求一直挂起，每个请求都会记录一个 client span
+var weatherHTTPClient = &http.Client{Timeout: 10 * time.Second, Transport: &tracing.Transport{}}
+
+// withoutRequestURL 去掉 http.Client 错误中的请求地址，其中的 API key 不应出现在错误信息和日志中
+func withoutRequestURL(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return fmt.Errorf("%s request failed: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
 }
 
 type WeatherGetter interface {
-	GetWeather(location string) (*CommonWeatherResponse, error)
+	GetWeather(ctx context.Context, location string) (*CommonWeatherResponse, error)
 }
 
 type CommonWeatherResponse struct {
@@ -98,7 +161,7 @@ func NewGoogleMapWeatherGetter() (WeatherGetter, error) {
 	return &GoogleMapWeatherGetter{apiKey: apiKey}, nil
 }
 
-func (g *GoogleMapWeatherGetter) GetWeather(location string) (*CommonWeatherResponse, error) {
+func (g *GoogleMapWeatherGetter) GetWeather(ctx context.Context, location string) (*CommonWeatherResponse, error) {
 	// TODO: Implement Google Map Weather API
 	return nil, errors.New("not implemented")
 }
@@ -125,7 +188,13 @@ type WeatherAPIResponse struct {
 	} `json:"current"`
 }
 
-func (w *WeatherAPIWeatherGetter) GetWeather(location string) (*CommonWeatherResponse, error) {
+func (w *WeatherAPIWeatherGetter) GetWeather(ctx context.Context, location string) (*CommonWeatherResponse, error) {
+	weather, err := w.fetch(ctx, location)
+	recordUpstream(ctx, WeatherProviderWeatherAPI, err)
+	return weather, err
+}
+
+func (w *WeatherAPIWeatherGetter) fetch(ctx context.Context, location string) (*CommonWeatherResponse, error) {
 	// 获取天气信息
 	params := &WeatherAPIParams{
 		APIKey: w.apiKey,
@@ -144,19 +213,33 @@ func (w *WeatherAPIWeatherGetter) GetWeather(location string) (*CommonWeatherRes
 	q.Add("aqi", params.AQI)
 	url.RawQuery = q.Encode()
 
-	resp, err := http.Get(url.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get weather API response")
+		return nil, errors.Wrap(err, "failed to create weather API request")
+	}
+
+	weatherAPIRequestsTotal.WithLabelValues(WeatherProviderWeatherAPI).Inc()
+	resp, err := weatherHTTPClient.Do(req)
+	if err != nil {
+		weatherAPIErrorsTotal.WithLabelValues(WeatherProviderWeatherAPI, weatherErrorTransport).Inc()
+		return nil, errors.Wrap(withoutRequestURL(err), "failed to get weather API response")
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		weatherAPIErrorsTotal.WithLabelValues(WeatherProviderWeatherAPI, weatherErrorStatus).Inc()
+		return nil, errors.Errorf("weather API returned status %d", resp.StatusCode)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
+		weatherAPIErrorsTotal.WithLabelValues(WeatherProviderWeatherAPI, weatherErrorDecode).Inc()
 		return nil, errors.Wrap(err, "failed to read weather API response body")
 	}
 
 	var weatherAPIResponse WeatherAPIResponse
 	if err := json.Unmarshal(body, &weatherAPIResponse); err != nil {
+		weatherAPIErrorsTotal.WithLabelValues(WeatherProviderWeatherAPI, weatherErrorDecode).Inc()
 		return nil, errors.Wrap(err, "failed to unmarshal weather API response")
 	}
 
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// UpstreamHealth 是某个上游服务 (例如天气 API 提供方) 最近的请求结果
+type UpstreamHealth struct {
+	LastSuccess         time.Time `json:"lastSuccess,omitzero"`
+	LastFailure         time.Time `json:"lastFailure,omitzero"`
+	LastError           string    `json:"lastError,omitempty"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+}
+
+var upstreams = struct {
+	sync.Mutex
+	health map[string]UpstreamHealth
+}{health: make(map[string]UpstreamHealth)}
+
+// UpstreamStatus 返回所有请求过的上游服务的健康状况，按名称索引
+func UpstreamStatus() map[string]UpstreamHealth {
+	upstreams.Lock()
+	defer upstreams.Unlock()
+
+	status := make(map[string]UpstreamHealth, len(upstreams.health))
+	for name, health := range upstreams.health {
+		status[name] = health
+	}
+	return status
+}
+
+// recordUpstream 记录一次上游请求的结果。ctx 已被取消 (客户端放弃或工具超时) 时的失败不代表上游不可用，不做记录
+func recordUpstream(ctx context.Context, name string, err error) {
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	upstreams.Lock()
+	defer upstreams.Unlock()
+	health := upstreams.health[name]
+	if err == nil {
+		health.LastSuccess = time.Now()
+		health.ConsecutiveFailures = 0
+	} else {
+		health.LastFailure = time.Now()
+		health.LastError = err.Error()
+		health.ConsecutiveFailures++
+	}
+	upstreams.health[name] = health
+}
@@ -0,0 +1,19 @@
+package tools
+
+import "github.com/n8sPxD/mcp-server-demo/metrics"
+
+var (
+	cacheLookupsTotal = metrics.NewCounterVec("mcp_tool_cache_lookups_total",
+		"Number of tool result cache lookups, by tool and result (hit or miss).", "tool", "result")
+	weatherAPIRequestsTotal = metrics.NewCounterVec("mcp_weather_api_requests_total",
+		"Number of requests sent to the upstream weather API, by provider.", "provider")
+	weatherAPIErrorsTotal = metrics.NewCounterVec("mcp_weather_api_errors_total",
+		"Number of failed upstream weather API requests, by provider and reason.", "provider", "reason")
+)
+
+// 天气 API 请求失败的原因
+const (
+	weatherErrorTransport = "transport" // 请求没有得到响应，例如网络错误或超时
+	weatherErrorStatus    = "status"    // 响应状态码不是 200
+	weatherErrorDecode    = "decode"    // 响应无法读取或解析
+)
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// CallRequest 描述一次工具调用
+type CallRequest struct {
+	ToolName string
+	Inputs   map[string]any
+}
+
+// Handler 处理一次工具调用
+type Handler func(ctx context.Context, req *CallRequest) (*ExecuteToolResult, error)
+
+// Middleware 包装 Handler，用于实现日志、计时、鉴权、限流、缓存等横切逻辑
+type Middleware func(next Handler) Handler
+
+// Chain 把中间件依次套在 h 外面，第一个中间件位于最外层
+func Chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Timing 记录每次工具调用的耗时
+func Timing(logger *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *CallRequest) (*ExecuteToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, req)
+			logger.InfoContext(ctx, "Tool call finished", "tool", req.ToolName, "duration", time.Since(start))
+			return result, err
+		}
+	}
+}
+
+// Logging 记录每次工具调用的参数和结果。参数和结果只在 debug 级别记录，敏感字段由日志的脱敏层处理
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *CallRequest) (*ExecuteToolResult, error) {
+			logger.DebugContext(ctx, "Executing tool", "tool", req.ToolName, "arguments", req.Inputs)
+
+			result, err := next(ctx, req)
+			if err != nil {
+				logger.WarnContext(ctx, "Tool failed", "tool", req.ToolName, "error", err)
+				return result, err
+			}
+
+			if logger.Enabled(ctx, slog.LevelDebug) {
+				output, _ := json.Marshal(result)
+				logger.DebugContext(ctx, "Tool returned", "tool", req.ToolName, "result", json.RawMessage(output))
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingMiddleware 在 calls 中记录进入和离开的顺序
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *CallRequest) (*ExecuteToolResult, error) {
+			*calls = append(*calls, name+" before")
+			result, err := next(ctx, req)
+			*calls = append(*calls, name+" after")
+			return result, err
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	h := Chain(func(ctx context.Context, req *CallRequest) (*ExecuteToolResult, error) {
+		calls = append(calls, "tool")
+		return NewTextResult("ok"), nil
+	}, recordingMiddleware("outer", &calls), recordingMiddleware("inner", &calls))
+
+	if _, err := h(context.Background(), &CallRequest{ToolName: "echo"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"outer before", "inner before", "tool", "inner after", "outer after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRegistryMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+		tool string
+		want []string
+	}{
+		{
+			name: "global and per-tool middlewares",
+			tool: "a",
+			want: []string{"global1 before", "global2 before", "a before", "tool", "a after", "global2 after", "global1 after"},
+		},
+		{
+			name: "per-tool middleware does not apply to other tools",
+			tool: "b",
+			want: []string{"global1 before", "global2 before", "tool", "global2 after", "global1 after"},
+		},
+		{
+			name: "alias uses the target's middlewares",
+			tool: "alias",
+			want: []string{"global1 before", "global2 before", "a before", "tool", "a after", "global2 after", "global1 after"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			fn := func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+				calls = append(calls, "tool")
+				return NewTextResult("ok"), nil
+			}
+			r := NewRegistry()
+			r.Register(ToolDefinition{Name: "a"}, fn)
+			r.Register(ToolDefinition{Name: "b"}, fn)
+			r.SetAliases(map[string]string{"alias": "a"})
+			r.Use(recordingMiddleware("global1", &calls))
+			r.Use(recordingMiddleware("global2", &calls))
+			if err := r.UseFor("a", recordingMiddleware("a", &calls)); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := r.Call(context.Background(), tt.tool, nil); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Errorf("calls = %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryMiddlewareSurvivesReregistration(t *testing.T) {
+	var calls []string
+	r := NewRegistry()
+	r.Register(ToolDefinition{Name: "a"}, echoTool)
+	if err := r.UseFor("a", recordingMiddleware("a", &calls)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UseFor("missing", recordingMiddleware("missing", &calls)); err == nil {
+		t.Error("UseFor accepted an unknown tool")
+	}
+
+	r.Register(ToolDefinition{Name: "a", Description: "replaced"}, echoTool)
+	if _, err := r.Call(context.Background(), "a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a before", "a after"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	tests := []struct {
+		name  string
+		level slog.Level
+		fn    ToolFunc
+		want  []string
+		skip  []string
+	}{
+		{
+			name:  "debug logs arguments and result",
+			level: slog.LevelDebug,
+			fn:    echoTool,
+			want:  []string{`msg="Executing tool"`, "arguments=map[city:Paris]", `msg="Tool returned"`},
+		},
+		{
+			name:  "info hides arguments and result",
+			level: slog.LevelInfo,
+			fn:    echoTool,
+			skip:  []string{"Executing tool", "Tool returned"},
+		},
+		{
+			name:  "errors are logged as warnings",
+			level: slog.LevelInfo,
+			fn: func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+				return nil, ErrToolTimeout
+			},
+			want: []string{"level=WARN", `msg="Tool failed"`, `error="tool execution timed out`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: tt.level}))
+			h := Chain(func(ctx context.Context, req *CallRequest) (*ExecuteToolResult, error) {
+				return tt.fn(ctx, req.Inputs)
+			}, Logging(logger))
+
+			h(context.Background(), &CallRequest{ToolName: "echo", Inputs: map[string]any{"city": "Paris"}})
+			out := buf.String()
+			for _, s := range tt.want {
+				if !strings.Contains(out, s) {
+					t.Errorf("log does not contain %s:\n%s", s, out)
+				}
+			}
+			for _, s := range tt.skip {
+				if strings.Contains(out, s) {
+					t.Errorf("log contains %s:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
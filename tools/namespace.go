@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SourceKind 表示工具的来源。发生重名时，数值越小的来源优先级越高
+type SourceKind int
+
+const (
+	SourceBuiltin SourceKind = iota // 编译进服务器的内置工具
+	SourcePlugin                    // 外部插件提供的工具
+	SourceProxy                     // 下游 MCP 服务器提供的工具
+)
+
+func (k SourceKind) String() string {
+	switch k {
+	case SourceBuiltin:
+		return "builtin"
+	case SourcePlugin:
+		return "plugin"
+	case SourceProxy:
+		return "proxy"
+	}
+	return fmt.Sprintf("source(%d)", int(k))
+}
+
+// Source 描述工具来自哪里，以及它的命名空间
+type Source struct {
+	Kind      SourceKind
+	Name      string // 插件或下游服务器的名称，内置工具为空
+	Namespace string // 非空时工具以 "<Namespace>.<name>" 的名称对外暴露
+}
+
+// BuiltinSource 是内置工具的来源
+var BuiltinSource = Source{Kind: SourceBuiltin}
+
+// QualifiedName 返回工具在该来源下对外暴露的名称
+func (s Source) QualifiedName(name string) string {
+	if s.Namespace == "" {
+		return name
+	}
+	return s.Namespace + "." + name
+}
+
+// Ref 返回工具在注册表中唯一的引用，形如 "builtin/get_weather" 或 "plugin:text/text_stats"，
+// 可以作为别名的目标来访问被同名工具遮蔽的工具
+func (s Source) Ref(name string) string {
+	if s.Name == "" {
+		return s.Kind.String() + "/" + name
+	}
+	return s.Kind.String() + ":" + s.Name + "/" + name
+}
+
+// higherPriority 判断 a 在重名时是否优先于 b：先比较来源类型，再比较来源名称
+func higherPriority(a, b *registeredTool) bool {
+	if a.source.Kind != b.source.Kind {
+		return a.source.Kind < b.source.Kind
+	}
+	if a.source.Name != b.source.Name {
+		return a.source.Name < b.source.Name
+	}
+	return a.ref < b.ref
+}
+
+// Collision 描述一个被多个工具争用的名称，以及按优先级策略的解析结果
+type Collision struct {
+	Name     string   `json:"name"`
+	Winner   string   `json:"winner"`   // 获得该名称的工具引用
+	Shadowed []string `json:"shadowed"` // 被遮蔽的工具引用或别名
+}
+
+func (c Collision) String() string {
+	return fmt.Sprintf("'%s' resolved to %s, shadowing %s", c.Name, c.Winner, strings.Join(c.Shadowed, ", "))
+}
+
+// resolveLocked 根据所有注册项和别名重新计算对外暴露的名称，调用者必须持有写锁。
+//
+// 解析策略：
+//  1. 注册项以 Source.QualifiedName 暴露，重名时按 内置 > 插件 > 下游 的顺序取胜，
+//     同类来源按来源名称的字典序取胜，其余注册项被遮蔽；
+//  2. 别名的目标可以是对外名称或工具引用 (Ref)，别名不能覆盖已有的工具名；
+//  3. 目标不存在的别名会被忽略并记录下来。
+func (r *Registry) resolveLocked() {
+	resolved := make(map[string]*registeredTool, len(r.entries))
+	shadowed := make(map[string][]string)
+
+	for _, entry := range r.entries {
+		current, ok := resolved[entry.name]
+		if !ok {
+			resolved[entry.name] = entry
+			continue
+		}
+		if higherPriority(entry, current) {
+			resolved[entry.name] = entry
+			shadowed[entry.name] = append(shadowed[entry.name], current.ref)
+		} else {
+			shadowed[entry.name] = append(shadowed[entry.name], entry.ref)
+		}
+	}
+
+	var unresolved []string
+	aliases := make([]string, 0, len(r.aliases))
+	for alias := range r.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	byRef := make(map[string]*registeredTool, len(r.entries))
+	for _, entry := range r.entries {
+		byRef[entry.ref] = entry
+	}
+	for _, alias := range aliases {
+		target := r.aliases[alias]
+		entry, ok := byRef[target]
+		if !ok {
+			entry, ok = resolved[target]
+		}
+		if !ok {
+			unresolved = append(unresolved, fmt.Sprintf("%s -> %s", alias, target))
+			continue
+		}
+		if existing, taken := resolved[alias]; taken && existing != entry {
+			shadowed[alias] = append(shadowed[alias], "alias "+alias+" -> "+target)
+			continue
+		}
+		resolved[alias] = entry
+	}
+
+	collisions := make([]Collision, 0, len(shadowed))
+	for name, refs := range shadowed {
+		sort.Strings(refs)
+		collisions = append(collisions, Collision{Name: name, Winner: resolved[name].ref, Shadowed: refs})
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Name < collisions[j].Name })
+
+	r.resolved = resolved
+	r.collisions = collisions
+	r.unresolvedAliases = unresolved
+}
+
+// SetAliases 替换全部别名，键为别名，值为目标工具的对外名称或引用
+func (r *Registry) SetAliases(aliases map[string]string) {
+	r.mu.Lock()
+	r.aliases = make(map[string]string, len(aliases))
+	for alias, target := range aliases {
+		r.aliases[alias] = target
+	}
+	r.resolveLocked()
+	r.mu.Unlock()
+
+	r.notify()
+}
+
+// Collisions 返回当前所有的名称冲突及其解析结果，按名称排序
+func (r *Registry) Collisions() []Collision {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Collision(nil), r.collisions...)
+}
+
+// UnresolvedAliases 返回目标不存在的别名，形如 "alias -> target"
+func (r *Registry) UnresolvedAliases() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.unresolvedAliases...)
+}
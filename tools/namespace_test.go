@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type testRegistration struct {
+	source Source
+	name   string
+}
+
+func newTestRegistry(registrations []testRegistration, aliases map[string]string) *Registry {
+	r := NewRegistry()
+	for _, reg := range registrations {
+		// Description 记录工具的引用，用于判断名称解析到了哪个工具
+		def := ToolDefinition{Name: reg.name, Description: reg.source.Ref(reg.name)}
+		r.RegisterFrom(reg.source, def, func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+			return NewTextResult(def.Description), nil
+		})
+	}
+	r.SetAliases(aliases)
+	return r
+}
+
+func TestNameResolution(t *testing.T) {
+	plugin := Source{Kind: SourcePlugin, Name: "text"}
+	namespaced := Source{Kind: SourcePlugin, Name: "text", Namespace: "text"}
+	proxyA := Source{Kind: SourceProxy, Name: "a"}
+	proxyB := Source{Kind: SourceProxy, Name: "b"}
+
+	tests := []struct {
+		name          string
+		registrations []testRegistration
+		aliases       map[string]string
+		want          map[string]string // 对外名称 -> 解析到的工具引用，空字符串表示不存在
+		collisions    []string
+		unresolved    []string
+	}{
+		{
+			name:          "builtin wins over plugin and proxy",
+			registrations: []testRegistration{{proxyA, "echo"}, {plugin, "echo"}, {BuiltinSource, "echo"}},
+			want:          map[string]string{"echo": "builtin/echo"},
+			collisions:    []string{"echo"},
+		},
+		{
+			name:          "same kind is ordered by source name",
+			registrations: []testRegistration{{proxyB, "echo"}, {proxyA, "echo"}},
+			want:          map[string]string{"echo": "proxy:a/echo"},
+			collisions:    []string{"echo"},
+		},
+		{
+			name:          "namespace avoids the collision",
+			registrations: []testRegistration{{BuiltinSource, "echo"}, {namespaced, "echo"}},
+			want:          map[string]string{"echo": "builtin/echo", "text.echo": "plugin:text/echo"},
+		},
+		{
+			name:          "alias by ref reaches a shadowed tool",
+			registrations: []testRegistration{{BuiltinSource, "echo"}, {plugin, "echo"}},
+			aliases:       map[string]string{"text_echo": "plugin:text/echo"},
+			want:          map[string]string{"echo": "builtin/echo", "text_echo": "plugin:text/echo"},
+			collisions:    []string{"echo"},
+		},
+		{
+			name:          "alias by exposed name",
+			registrations: []testRegistration{{namespaced, "stats"}},
+			aliases:       map[string]string{"stats": "text.stats"},
+			want:          map[string]string{"stats": "plugin:text/stats", "text.stats": "plugin:text/stats"},
+		},
+		{
+			name:          "alias cannot take an existing name",
+			registrations: []testRegistration{{BuiltinSource, "echo"}, {BuiltinSource, "other"}},
+			aliases:       map[string]string{"echo": "other"},
+			want:          map[string]string{"echo": "builtin/echo"},
+			collisions:    []string{"echo"},
+		},
+		{
+			name:          "alias with a missing target is ignored",
+			registrations: []testRegistration{{BuiltinSource, "echo"}},
+			aliases:       map[string]string{"gone": "missing"},
+			want:          map[string]string{"gone": ""},
+			unresolved:    []string{"gone -> missing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry(tt.registrations, tt.aliases)
+			for name, wantRef := range tt.want {
+				def, ok := r.GetTool(name)
+				if wantRef == "" {
+					if ok {
+						t.Errorf("%s resolved to %s, want no tool", name, def.Description)
+					}
+					continue
+				}
+				if !ok || def.Description != wantRef {
+					t.Errorf("%s resolved to %q (found %v), want %q", name, def.Description, ok, wantRef)
+				}
+			}
+
+			var collisions []string
+			for _, c := range r.Collisions() {
+				collisions = append(collisions, c.Name)
+			}
+			if !slices.Equal(collisions, tt.collisions) {
+				t.Errorf("collisions = %v, want %v", collisions, tt.collisions)
+			}
+			if got := r.UnresolvedAliases(); !slices.Equal(got, tt.unresolved) {
+				t.Errorf("unresolved aliases = %v, want %v", got, tt.unresolved)
+			}
+		})
+	}
+}
+
+func TestUnregisterAlias(t *testing.T) {
+	r := newTestRegistry([]testRegistration{{BuiltinSource, "echo"}}, map[string]string{"say": "echo"})
+
+	if !r.Unregister("say") {
+		t.Fatal("unregistering an alias should report that it existed")
+	}
+	if _, ok := r.GetTool("say"); ok {
+		t.Error("alias should be removed")
+	}
+	if _, ok := r.GetTool("echo"); !ok {
+		t.Error("unregistering an alias must not remove its target")
+	}
+
+	if !r.Unregister("echo") {
+		t.Fatal("unregistering a tool by its name should report that it existed")
+	}
+	if _, ok := r.GetTool("echo"); ok {
+		t.Error("tool should be removed")
+	}
+}
+
+func TestAliasSharesPanicCount(t *testing.T) {
+	r := NewRegistry()
+	r.Register(ToolDefinition{Name: "alias_panic_test"}, func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		panic("boom")
+	})
+	r.SetAliases(map[string]string{"alias_panic_test_alias": "alias_panic_test"})
+
+	before := PanicCounts()
+	for _, name := range []string{"alias_panic_test", "alias_panic_test_alias"} {
+		_, err := r.Call(context.Background(), name, nil)
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			t.Fatalf("calling %s: err = %v, want *PanicError", name, err)
+		}
+	}
+
+	after := PanicCounts()
+	if got := after["alias_panic_test"] - before["alias_panic_test"]; got != 2 {
+		t.Errorf("panic count for target grew by %d, want 2", got)
+	}
+	if _, ok := after["alias_panic_test_alias"]; ok {
+		t.Error("panics should not be counted under the alias")
+	}
+}
@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 插件协议：
+//
+//	<command> [args...] describe
+//	    在 stdout 输出 {"tools": [ToolDefinition...]}
+//	<command> [args...] call <tool name>
+//	    从 stdin 读取 JSON 参数对象，在 stdout 输出 ExecuteToolResult。
+//	    非零退出码表示执行失败，stderr 的内容会作为错误信息返回
+//
+// 插件可以用任何语言实现，协议处理、参数校验和日志由 Go 服务器负责
+
+// pluginDescribeTimeout 是启动时获取插件工具描述的时限
+const pluginDescribeTimeout = 10 * time.Second
+
+// maxPluginStderr 是错误信息中保留的 stderr 最大字节数
+const maxPluginStderr = 4096
+
+// PluginConfig 描述一个外部工具插件
+type PluginConfig struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace,omitempty"` // 非空时插件的工具以 "<namespace>.<name>" 暴露
+	Command   string   `json:"command"`
+	Args      []string `json:"args,omitempty"`
+	Env       []string `json:"env,omitempty"` // KEY=VALUE，追加到当前进程的环境变量之后
+	Dir       string   `json:"dir,omitempty"` // 工作目录
+}
+
+// Plugin 是一个通过 stdin/stdout 调用的外部工具进程
+type Plugin struct {
+	config PluginConfig
+	logger *slog.Logger
+}
+
+func NewPlugin(config PluginConfig, logger *slog.Logger) *Plugin {
+	return &Plugin{config: config, logger: logger}
+}
+
+// Name 返回插件名称
+func (p *Plugin) Name() string {
+	return p.config.Name
+}
+
+// Describe 运行插件的 describe 命令，返回插件提供的工具定义
+func (p *Plugin) Describe(ctx context.Context) ([]ToolDefinition, error) {
+	ctx, cancel := context.WithTimeout(ctx, pluginDescribeTimeout)
+	defer cancel()
+
+	stdout, err := p.run(ctx, nil, "describe")
+	if err != nil {
+		return nil, err
+	}
+
+	var described struct {
+		Tools []ToolDefinition `json:"tools"`
+	}
+	if err := json.Unmarshal(stdout, &described); err != nil {
+		return nil, errors.Wrapf(err, "plugin '%s' returned an invalid tool description", p.config.Name)
+	}
+	for _, def := range described.Tools {
+		if def.Name == "" {
+			return nil, fmt.Errorf("plugin '%s' described a tool without a name", p.config.Name)
+		}
+	}
+	return described.Tools, nil
+}
+
+// ToolFunc 返回调用插件中某个工具的执行函数。调用前会按工具的 inputSchema 校验参数
+func (p *Plugin) ToolFunc(def ToolDefinition) ToolFunc {
+	return func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		if inputs == nil {
+			inputs = map[string]any{}
+		}
+		if err := ValidateAgainstSchema(def.InputSchema, inputs); err != nil {
+			return nil, errors.Wrapf(err, "invalid arguments for tool '%s'", def.Name)
+		}
+
+		input, err := json.Marshal(inputs)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal plugin arguments")
+		}
+
+		stdout, err := p.run(ctx, input, "call", def.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		var result ExecuteToolResult
+		if err := json.Unmarshal(stdout, &result); err != nil {
+			return nil, errors.Wrapf(err, "plugin '%s' returned an invalid result for tool '%s'", p.config.Name, def.Name)
+		}
+		return &result, nil
+	}
+}
+
+// Source 返回插件工具在注册表中的来源
+func (p *Plugin) Source() Source {
+	return Source{Kind: SourcePlugin, Name: p.config.Name, Namespace: p.config.Namespace}
+}
+
+// Register 获取插件的工具描述并把它们注册到注册表中，返回对外暴露的工具名。
+// 可以重复调用以在插件更新后重新同步
+func (p *Plugin) Register(ctx context.Context, registry *Registry) ([]string, error) {
+	defs, err := p.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 重新注册时先移除插件旧的工具，插件不再提供的工具随之消失。
+	// 工具描述获取失败时保留已注册的工具
+	source := p.Source()
+	names := make([]string, 0, len(defs))
+	registry.Batch(func() {
+		registry.UnregisterSource(source)
+		for _, def := range defs {
+			registry.RegisterFrom(source, def, p.ToolFunc(def))
+			names = append(names, source.QualifiedName(def.Name))
+		}
+	})
+	return names, nil
+}
+
+// DiscoverPlugins 把目录中的每个可执行文件作为一个插件，插件名为去掉扩展名的文件名。
+// 隐藏文件和子目录会被忽略，结果按插件名排序
+func DiscoverPlugins(dir string) ([]PluginConfig, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read plugin directory")
+	}
+
+	var configs []PluginConfig
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		command, err := filepath.Abs(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve plugin path")
+		}
+		configs = append(configs, PluginConfig{
+			Name:    strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+			Command: command,
+		})
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+	return configs, nil
+}
+
+// run 启动一次插件进程，ctx 被取消时进程会被杀死
+func (p *Plugin) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, p.config.Command, append(append([]string{}, p.config.Args...), args...)...)
+	cmd.Dir = p.config.Dir
+	if len(p.config.Env) > 0 {
+		cmd.Env = append(os.Environ(), p.config.Env...)
+	}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if stderr.Len() > 0 && p.logger != nil {
+		p.logger.Warn("Plugin wrote to stderr", "plugin", p.config.Name, "command", args[0], "stderr", stderr.String())
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.Wrapf(ctx.Err(), "plugin '%s' was cancelled", p.config.Name)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > maxPluginStderr {
+			msg = msg[len(msg)-maxPluginStderr:]
+		}
+		if msg == "" {
+			return nil, errors.Wrapf(err, "plugin '%s' failed", p.config.Name)
+		}
+		return nil, fmt.Errorf("plugin '%s' failed: %v: %s", p.config.Name, err, msg)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,244 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// helperPluginEnv 选择 TestHelperPlugin 的行为，为空时它是普通的测试
+const helperPluginEnv = "GO_HELPER_PLUGIN"
+
+// TestHelperPlugin 不是真正的测试：helperPlugin 以它为入口重新运行测试二进制，
+// 使其按插件协议工作。"--" 之后的参数是插件命令
+func TestHelperPlugin(t *testing.T) {
+	mode := os.Getenv(helperPluginEnv)
+	if mode == "" {
+		return
+	}
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	os.Exit(runHelperPlugin(mode, args))
+}
+
+func runHelperPlugin(mode string, args []string) int {
+	switch {
+	case len(args) == 1 && args[0] == "describe":
+		switch mode {
+		case "invalid-description":
+			fmt.Print("not json")
+		case "unnamed-tool":
+			fmt.Print(`{"tools":[{"description":"no name"}]}`)
+		case "broken":
+			fmt.Fprint(os.Stderr, "cannot start")
+			return 1
+		default:
+			fmt.Print(`{"tools":[
+				{"name":"echo","inputSchema":{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]}},
+				{"name":"fail","inputSchema":{"type":"object"}},
+				{"name":"silent_fail","inputSchema":{"type":"object"}},
+				{"name":"invalid_result","inputSchema":{"type":"object"}},
+				{"name":"sleep","inputSchema":{"type":"object"}}
+			]}`)
+		}
+		return 0
+	case len(args) == 2 && args[0] == "call":
+		var inputs map[string]any
+		if err := json.NewDecoder(os.Stdin).Decode(&inputs); err != nil {
+			fmt.Fprint(os.Stderr, "invalid arguments: ", err)
+			return 2
+		}
+		switch args[1] {
+		case "echo":
+			json.NewEncoder(os.Stdout).Encode(NewTextResult(inputs["text"].(string)))
+		case "fail":
+			fmt.Fprint(os.Stderr, "something broke")
+			return 3
+		case "silent_fail":
+			return 4
+		case "invalid_result":
+			fmt.Print("{")
+		case "sleep":
+			time.Sleep(time.Minute)
+		}
+		return 0
+	}
+	fmt.Fprint(os.Stderr, "usage: describe | call <tool name>")
+	return 2
+}
+
+// helperPlugin 返回以测试二进制作为可执行文件、按 mode 工作的插件
+func helperPlugin(name, mode string) *Plugin {
+	return NewPlugin(PluginConfig{
+		Name:    name,
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^TestHelperPlugin$", "--"},
+		Env:     []string{helperPluginEnv + "=" + mode},
+	}, nil)
+}
+
+func TestPluginDescribe(t *testing.T) {
+	tests := []struct {
+		mode      string
+		wantTools int
+		wantErr   string
+	}{
+		{mode: "ok", wantTools: 5},
+		{mode: "invalid-description", wantErr: "invalid tool description"},
+		{mode: "unnamed-tool", wantErr: "described a tool without a name"},
+		{mode: "broken", wantErr: "cannot start"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			defs, err := helperPlugin("helper", tt.mode).Describe(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(defs) != tt.wantTools {
+				t.Errorf("got %d tools, want %d", len(defs), tt.wantTools)
+			}
+		})
+	}
+}
+
+func TestPluginCall(t *testing.T) {
+	plugin := helperPlugin("helper", "ok")
+	defs, err := plugin.Describe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]ToolDefinition, len(defs))
+	for _, def := range defs {
+		byName[def.Name] = def
+	}
+
+	tests := []struct {
+		name     string
+		tool     string
+		inputs   map[string]any
+		timeout  time.Duration
+		wantText string
+		wantErr  string
+	}{
+		{name: "success", tool: "echo", inputs: map[string]any{"text": "hello"}, wantText: "hello"},
+		{name: "arguments fail the schema", tool: "echo", inputs: map[string]any{"text": 1}, wantErr: "invalid arguments for tool 'echo'"},
+		{name: "non-zero exit with stderr", tool: "fail", wantErr: "plugin 'helper' failed: exit status 3: something broke"},
+		{name: "non-zero exit without stderr", tool: "silent_fail", wantErr: "plugin 'helper' failed: exit status 4"},
+		{name: "invalid JSON result", tool: "invalid_result", wantErr: "returned an invalid result for tool 'invalid_result'"},
+		{name: "cancelled", tool: "sleep", timeout: 100 * time.Millisecond, wantErr: "was cancelled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			result, err := plugin.ToolFunc(byName[tt.tool])(ctx, tt.inputs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text, ok := result.Content[0].(*TextContent); !ok || text.Text != tt.wantText {
+				t.Errorf("content = %+v, want %q", result.Content[0], tt.wantText)
+			}
+		})
+	}
+}
+
+func TestPluginRegister(t *testing.T) {
+	r := NewRegistry()
+	config := helperPlugin("helper", "ok").config
+	config.Namespace = "ext"
+	plugin := NewPlugin(config, nil)
+
+	names, err := plugin.Register(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ext.echo", "ext.fail", "ext.silent_fail", "ext.invalid_result", "ext.sleep"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	result, err := r.Call(context.Background(), "ext.echo", map[string]any{"text": "via registry"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := result.Content[0].(*TextContent).Text; text != "via registry" {
+		t.Errorf("text = %q", text)
+	}
+
+	// 描述获取失败时保留已注册的工具
+	broken := NewPlugin(PluginConfig{Name: "helper", Namespace: "ext", Command: config.Command, Args: config.Args, Env: []string{helperPluginEnv + "=broken"}}, nil)
+	if _, err := broken.Register(context.Background(), r); err == nil {
+		t.Fatal("Register succeeded with a broken plugin")
+	}
+	if _, ok := r.GetTool("ext.echo"); !ok {
+		t.Error("a failed re-registration removed the plugin's tools")
+	}
+}
+
+func TestDiscoverPlugins(t *testing.T) {
+	dir := t.TempDir()
+	files := []struct {
+		name string
+		mode os.FileMode
+	}{
+		{name: "b_tool.py", mode: 0755},
+		{name: "a_tool", mode: 0700},
+		{name: "README.md", mode: 0644},
+		{name: ".hidden", mode: 0755},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), nil, f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err := DiscoverPlugins(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PluginConfig{
+		{Name: "a_tool", Command: filepath.Join(dir, "a_tool")},
+		{Name: "b_tool", Command: filepath.Join(dir, "b_tool.py")},
+	}
+	if !reflect.DeepEqual(configs, want) {
+		t.Errorf("configs = %+v, want %+v", configs, want)
+	}
+
+	if _, err := DiscoverPlugins(filepath.Join(dir, "missing")); err == nil {
+		t.Error("DiscoverPlugins succeeded on a missing directory")
+	} else if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err = %v, want it to wrap os.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimit 配置令牌桶限流和最大并发数，字段为零值表示对应维度不限制
+type RateLimit struct {
+	Rate          float64 // 每秒补充的令牌数
+	Burst         int     // 令牌桶容量，为 0 时取 max(1, ceil(Rate))
+	MaxConcurrent int     // 同时执行的最大调用数
+}
+
+func (l RateLimit) isZero() bool {
+	return l.Rate <= 0 && l.MaxConcurrent <= 0
+}
+
+// concurrencyRetryAfter 是因并发数超限被拒绝时建议的重试间隔
+const concurrencyRetryAfter = time.Second
+
+// RateLimitError 表示调用超过了限流或并发限制
+type RateLimitError struct {
+	Tool       string
+	PerSession bool // 是否是按会话的限制
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	scope := "tool"
+	if e.PerSession {
+		scope = "session"
+	}
+	return fmt.Sprintf("rate limit exceeded for tool '%s' (%s limit: %s), retry after %.1fs",
+		e.Tool, scope, e.Reason, e.RetryAfter.Seconds())
+}
+
+// tokenBucket 是一个简单的令牌桶
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// take 尝试取出一个令牌，失败时返回需要等待的时间
+func (b *tokenBucket) take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// refund 归还一个已取出但没有用掉的令牌
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// limiter 组合了令牌桶和并发信号量
+type limiter struct {
+	bucket *tokenBucket  // 为 nil 表示不限速
+	sem    chan struct{} // 为 nil 表示不限并发
+}
+
+func newLimiter(limit RateLimit) *limiter {
+	l := &limiter{}
+	if limit.Rate > 0 {
+		l.bucket = newTokenBucket(limit.Rate, limit.Burst)
+	}
+	if limit.MaxConcurrent > 0 {
+		l.sem = make(chan struct{}, limit.MaxConcurrent)
+	}
+	return l
+}
+
+// acquire 尝试获取一次调用的许可，成功时返回用于释放并发名额的函数
+func (l *limiter) acquire() (release func(), reason string, retryAfter time.Duration) {
+	release = func() {}
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+			release = func() { <-l.sem }
+		default:
+			return nil, fmt.Sprintf("max %d concurrent calls", cap(l.sem)), concurrencyRetryAfter
+		}
+	}
+	if l.bucket != nil {
+		if ok, wait := l.bucket.take(); !ok {
+			release()
+			return nil, fmt.Sprintf("%.2f calls/s", l.bucket.rate), wait
+		}
+	}
+	return release, "", 0
+}
+
+// refund 归还 acquire 取出的令牌，用于调用随后被其他限制拒绝的情况
+func (l *limiter) refund() {
+	if l.bucket != nil {
+		l.bucket.refund()
+	}
+}
+
+// callSlots 持有一次调用占用的并发名额。调用方和执行工具的 goroutine 各持有一个引用，
+// 两者都结束后才释放名额，这样因超时被放弃但仍在运行的工具继续占用名额，不会突破并发上限
+type callSlots struct {
+	refs     atomic.Int32
+	releases []func()
+}
+
+func newCallSlots() *callSlots {
+	s := &callSlots{}
+	s.refs.Store(1)
+	return s
+}
+
+// hold 为执行工具的 goroutine 增加一个引用
+func (s *callSlots) hold() {
+	s.refs.Add(1)
+}
+
+// done 释放一个引用，最后一个引用释放时归还所有名额
+func (s *callSlots) done() {
+	if s.refs.Add(-1) == 0 {
+		for _, release := range s.releases {
+			release()
+		}
+	}
+}
+
+type callSlotsKey struct{}
+
+// holdCallSlots 为 ctx 中的并发名额增加一个引用，返回释放该引用的函数
+func holdCallSlots(ctx context.Context) (done func()) {
+	s, ok := ctx.Value(callSlotsKey{}).(*callSlots)
+	if !ok {
+		return func() {}
+	}
+	s.hold()
+	return s.done
+}
+
+// toolLimits 保存一个工具的全局限制和按会话的限制
+type toolLimits struct {
+	mu           sync.Mutex // 保护以下所有字段，限制可能在调用进行中被修改
+	global       *limiter
+	sessionLimit RateLimit
+	sessions     map[string]*limiter
+}
+
+// setGlobal 替换工具级限制，零值表示取消限制
+func (t *toolLimits) setGlobal(limit RateLimit) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.global = nil
+	if !limit.isZero() {
+		t.global = newLimiter(limit)
+	}
+}
+
+// setSession 替换会话级限制并清空已有的会话限流器
+func (t *toolLimits) setSession(limit RateLimit) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sessionLimit = limit
+	t.sessions = make(map[string]*limiter)
+}
+
+func (t *toolLimits) globalLimiter() *limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.global
+}
+
+func (t *toolLimits) sessionLimiter(sessionID string) *limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.sessionLimit.isZero() {
+		return nil
+	}
+	l, ok := t.sessions[sessionID]
+	if !ok {
+		l = newLimiter(t.sessionLimit)
+		t.sessions[sessionID] = l
+	}
+	return l
+}
+
+func (t *toolLimits) forgetSession(sessionID string) {
+	t.mu.Lock()
+	delete(t.sessions, sessionID)
+	t.mu.Unlock()
+}
+
+// rateLimited 在调用 next 之前依次检查会话级和工具级限制。会话级限制先检查，
+// 已经超出自身限制的会话不会消耗所有会话共享的工具级配额；
+// 工具级限制拒绝调用时归还会话级令牌
+func (t *toolLimits) rateLimited(next Handler) Handler {
+	return func(ctx context.Context, req *CallRequest) (*ExecuteToolResult, error) {
+		slots := newCallSlots()
+		defer slots.done()
+
+		session := t.sessionLimiter(SessionIDFromContext(ctx))
+		if session != nil {
+			release, reason, retryAfter := session.acquire()
+			if release == nil {
+				return nil, &RateLimitError{Tool: req.ToolName, PerSession: true, Reason: reason, RetryAfter: retryAfter}
+			}
+			slots.releases = append(slots.releases, release)
+		}
+		if l := t.globalLimiter(); l != nil {
+			release, reason, retryAfter := l.acquire()
+			if release == nil {
+				if session != nil {
+					session.refund()
+				}
+				return nil, &RateLimitError{Tool: req.ToolName, Reason: reason, RetryAfter: retryAfter}
+			}
+			slots.releases = append(slots.releases, release)
+		}
+		return next(context.WithValue(ctx, callSlotsKey{}, slots), req)
+	}
+}
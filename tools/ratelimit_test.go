@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		burst   int
+		calls   int
+		allowed int
+	}{
+		{name: "burst allows calls up front", rate: 0.001, burst: 3, calls: 5, allowed: 3},
+		{name: "zero burst defaults to ceil(rate)", rate: 2.5, burst: 0, calls: 5, allowed: 3},
+		{name: "zero burst with slow rate allows one", rate: 0.001, burst: 0, calls: 3, allowed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := newTokenBucket(tt.rate, tt.burst)
+			allowed := 0
+			for i := 0; i < tt.calls; i++ {
+				ok, wait := bucket.take()
+				if ok {
+					allowed++
+				} else if wait <= 0 {
+					t.Errorf("call %d rejected with non-positive wait %v", i, wait)
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d calls, want %d", allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	l := newLimiter(RateLimit{MaxConcurrent: 2})
+
+	release1, _, _ := l.acquire()
+	release2, _, _ := l.acquire()
+	if release1 == nil || release2 == nil {
+		t.Fatal("first two calls should be admitted")
+	}
+	if release, reason, retryAfter := l.acquire(); release != nil {
+		t.Fatal("third concurrent call should be rejected")
+	} else if reason == "" || retryAfter != concurrencyRetryAfter {
+		t.Errorf("got reason %q and retry after %v", reason, retryAfter)
+	}
+
+	release1()
+	release3, _, _ := l.acquire()
+	if release3 == nil {
+		t.Fatal("call should be admitted after a slot is released")
+	}
+	release2()
+	release3()
+}
+
+func TestRegistryRateLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		global      RateLimit
+		session     RateLimit
+		sessions    []string // 依次发起调用的会话
+		wantLimited []bool
+		perSession  bool // 被拒绝时是否是会话级限制
+	}{
+		{
+			name:        "global limit is shared by sessions",
+			global:      RateLimit{Rate: 0.001, Burst: 2},
+			sessions:    []string{"a", "b", "a"},
+			wantLimited: []bool{false, false, true},
+		},
+		{
+			name:        "session limit is per session",
+			session:     RateLimit{Rate: 0.001, Burst: 1},
+			sessions:    []string{"a", "b", "a", "b"},
+			wantLimited: []bool{false, false, true, true},
+			perSession:  true,
+		},
+		{
+			name:        "session over its limit does not use the global quota",
+			global:      RateLimit{Rate: 0.001, Burst: 2},
+			session:     RateLimit{Rate: 0.001, Burst: 1},
+			sessions:    []string{"a", "a", "a", "b"},
+			wantLimited: []bool{false, true, true, false},
+			perSession:  true,
+		},
+		{
+			name:        "global rejection refunds the session token",
+			global:      RateLimit{Rate: 0.001, Burst: 1},
+			session:     RateLimit{Rate: 0.001, Burst: 1},
+			sessions:    []string{"a", "b", "b"},
+			wantLimited: []bool{false, true, true},
+		},
+		{
+			name:        "zero limit does not limit",
+			sessions:    []string{"a", "a", "a"},
+			wantLimited: []bool{false, false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.Register(ToolDefinition{Name: "echo"}, func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+				return NewTextResult("ok"), nil
+			})
+			if err := r.SetRateLimit("echo", tt.global); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.SetSessionRateLimit("echo", tt.session); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, sessionID := range tt.sessions {
+				_, err := r.Call(WithSessionID(context.Background(), sessionID), "echo", nil)
+				var rateLimitErr *RateLimitError
+				limited := errors.As(err, &rateLimitErr)
+				if limited != tt.wantLimited[i] {
+					t.Fatalf("call %d (session %s): limited = %v, want %v (err: %v)", i, sessionID, limited, tt.wantLimited[i], err)
+				}
+				if limited && rateLimitErr.PerSession != tt.perSession {
+					t.Errorf("call %d: PerSession = %v, want %v", i, rateLimitErr.PerSession, tt.perSession)
+				}
+			}
+		})
+	}
+}
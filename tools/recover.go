@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError 表示工具在执行过程中发生了 panic
+type PanicError struct {
+	Tool  string
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("tool '%s' panicked: %v", e.Tool, e.Value)
+}
+
+// panicStats 记录每个工具发生 panic 的次数
+var panicStats = struct {
+	sync.Mutex
+	counts map[string]int64
+}{counts: make(map[string]int64)}
+
+// recoverPanic 必须通过 defer 调用，它把工具执行中的 panic 恢复为 *PanicError 并计数，
+// 这样单个工具出错只会影响本次调用，而不会让整个 stdio 服务器崩溃
+func recoverPanic(name string, result **ExecuteToolResult, err *error) {
+	if r := recover(); r != nil {
+		panicStats.Lock()
+		panicStats.counts[name]++
+		panicStats.Unlock()
+
+		*result = nil
+		*err = &PanicError{Tool: name, Value: r, Stack: debug.Stack()}
+	}
+}
+
+// PanicCounts 返回每个工具 panic 次数的快照，用于诊断
+func PanicCounts() map[string]int64 {
+	panicStats.Lock()
+	defer panicStats.Unlock()
+
+	counts := make(map[string]int64, len(panicStats.counts))
+	for name, count := range panicStats.counts {
+		counts[name] = count
+	}
+	return counts
+}
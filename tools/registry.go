@@ -0,0 +1,438 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrToolNotFound 表示工具不存在或已被禁用
+var ErrToolNotFound = errors.New("tool not found")
+
+// registeredTool 是注册表中的一个工具及其状态
+type registeredTool struct {
+	source      Source
+	ref         string // 在注册表中唯一的引用，见 Source.Ref
+	name        string // 对外暴露的名称
+	def         ToolDefinition
+	fn          ToolFunc
+	enabled     bool
+	timeout     time.Duration // 为 0 时使用注册表的默认超时
+	limits      *toolLimits   // 为 nil 表示不限流
+	cache       *resultCache  // 为 nil 表示不缓存
+	middlewares []Middleware  // 只作用于该工具的中间件
+}
+
+// Registry 是线程安全的工具注册表，支持在运行时添加、删除、启用和禁用工具。
+// 工具可以来自不同的来源并带有命名空间，重名时按固定的优先级解析，
+// 工具集合发生变化时会通知所有监听者
+type Registry struct {
+	mu                sync.RWMutex
+	entries           map[string]*registeredTool // 按 ref 索引的全部注册项
+	aliases           map[string]string          // 别名 -> 目标
+	resolved          map[string]*registeredTool // 对外名称 (包括别名) -> 注册项
+	collisions        []Collision
+	unresolvedAliases []string
+	middlewares       []Middleware // 作用于所有工具的中间件
+	defaultTimeout    time.Duration
+	listeners         map[int]func()
+	nextID            int
+	batchDepth        int  // 大于 0 时推迟变更通知，见 Batch
+	batchPending      bool // 批量修改期间是否有被推迟的通知
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		entries:        make(map[string]*registeredTool),
+		aliases:        make(map[string]string),
+		resolved:       make(map[string]*registeredTool),
+		defaultTimeout: DefaultToolTimeout,
+		listeners:      make(map[int]func()),
+	}
+}
+
+// NewDefaultRegistry 创建一个包含所有内置工具的注册表
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, tool := range supportTools {
+		r.RegisterFrom(BuiltinSource, tool, ToolFuncMap[tool.Name])
+	}
+	return r
+}
+
+// Register 注册一个内置工具，同名的内置工具会被替换
+func (r *Registry) Register(def ToolDefinition, fn ToolFunc) {
+	r.RegisterFrom(BuiltinSource, def, fn)
+}
+
+// RegisterFrom 注册一个来自 source 的工具。同一来源下的同名工具会被替换，
+// 并保留原有的启用状态、超时、限流、缓存和中间件设置
+func (r *Registry) RegisterFrom(source Source, def ToolDefinition, fn ToolFunc) {
+	entry := &registeredTool{
+		source:  source,
+		ref:     source.Ref(def.Name),
+		name:    source.QualifiedName(def.Name),
+		def:     def,
+		fn:      fn,
+		enabled: true,
+	}
+	entry.def.Name = entry.name
+
+	r.mu.Lock()
+	if old, ok := r.entries[entry.ref]; ok {
+		entry.enabled = old.enabled
+		entry.timeout = old.timeout
+		entry.limits = old.limits
+		entry.cache = old.cache
+		entry.middlewares = old.middlewares
+	}
+	r.entries[entry.ref] = entry
+	r.resolveLocked()
+	r.mu.Unlock()
+
+	r.notify()
+}
+
+// Unregister 删除对外名称为 name 的工具，返回该工具是否存在。
+// name 是别名时只删除别名，目标工具保持不变
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	entry, ok := r.resolved[name]
+	if ok {
+		if entry.name != name {
+			delete(r.aliases, name)
+		} else {
+			delete(r.entries, entry.ref)
+		}
+		r.resolveLocked()
+	}
+	r.mu.Unlock()
+
+	if ok {
+		r.notify()
+	}
+	return ok
+}
+
+// UnregisterFrom 删除来自 source 的工具，name 是注册时的原始名称
+func (r *Registry) UnregisterFrom(source Source, name string) bool {
+	r.mu.Lock()
+	ref := source.Ref(name)
+	_, ok := r.entries[ref]
+	if ok {
+		delete(r.entries, ref)
+		r.resolveLocked()
+	}
+	r.mu.Unlock()
+
+	if ok {
+		r.notify()
+	}
+	return ok
+}
+
+// UnregisterSource 删除来自 source 的所有工具，返回删除的数量
+func (r *Registry) UnregisterSource(source Source) int {
+	r.mu.Lock()
+	removed := 0
+	for ref, entry := range r.entries {
+		if entry.source.Kind == source.Kind && entry.source.Name == source.Name {
+			delete(r.entries, ref)
+			removed++
+		}
+	}
+	if removed > 0 {
+		r.resolveLocked()
+	}
+	r.mu.Unlock()
+
+	if removed > 0 {
+		r.notify()
+	}
+	return removed
+}
+
+// SetEnabled 启用或禁用一个工具，被禁用的工具不会出现在列表中，也不能被调用
+func (r *Registry) SetEnabled(name string, enabled bool) error {
+	r.mu.Lock()
+	tool, ok := r.resolved[name]
+	if !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("tool '%s' not found", name)
+	}
+	changed := tool.enabled != enabled
+	tool.enabled = enabled
+	r.mu.Unlock()
+
+	if changed {
+		r.notify()
+	}
+	return nil
+}
+
+// List 返回所有已启用工具的定义，按名称排序
+func (r *Registry) List() []ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]ToolDefinition, 0, len(r.resolved))
+	for name, tool := range r.resolved {
+		if tool.enabled {
+			def := tool.def
+			def.Name = name // 别名以自己的名称列出
+			defs = append(defs, def)
+		}
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// GetTool 返回已启用工具的定义
+func (r *Registry) GetTool(name string) (ToolDefinition, bool) {
+	def, _, ok := r.Lookup(name)
+	return def, ok
+}
+
+// Lookup 返回已启用工具的定义和执行函数
+func (r *Registry) Lookup(name string) (ToolDefinition, ToolFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tool, ok := r.resolved[name]
+	if !ok || !tool.enabled {
+		return ToolDefinition{}, nil, false
+	}
+	def := tool.def
+	def.Name = name
+	return def, tool.fn, true
+}
+
+// Use 添加作用于所有工具的中间件，先添加的位于外层
+func (r *Registry) Use(middlewares ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// UseFor 添加只作用于指定工具的中间件，它们位于全局中间件之内
+func (r *Registry) UseFor(name string, middlewares ...Middleware) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tool, ok := r.resolved[name]
+	if !ok {
+		return fmt.Errorf("tool '%s' not found", name)
+	}
+	tool.middlewares = append(tool.middlewares, middlewares...)
+	return nil
+}
+
+// SetDefaultTimeout 设置未单独配置超时的工具的执行时限，0 表示不限制
+func (r *Registry) SetDefaultTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultTimeout = timeout
+}
+
+// SetTimeout 设置单个工具的执行时限，0 表示使用默认超时
+func (r *Registry) SetTimeout(name string, timeout time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tool, ok := r.resolved[name]
+	if !ok {
+		return fmt.Errorf("tool '%s' not found", name)
+	}
+	tool.timeout = timeout
+	return nil
+}
+
+// SetRateLimit 设置工具在所有会话间共享的限流和并发限制，零值表示取消限制
+func (r *Registry) SetRateLimit(name string, limit RateLimit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tool, ok := r.resolved[name]
+	if !ok {
+		return fmt.Errorf("tool '%s' not found", name)
+	}
+	tool.ensureLimits().setGlobal(limit)
+	return nil
+}
+
+// SetSessionRateLimit 设置工具在每个会话内的限流和并发限制，零值表示取消限制
+func (r *Registry) SetSessionRateLimit(name string, limit RateLimit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tool, ok := r.resolved[name]
+	if !ok {
+		return fmt.Errorf("tool '%s' not found", name)
+	}
+	tool.ensureLimits().setSession(limit)
+	return nil
+}
+
+// EnableCache 为工具开启结果缓存。只有标注了 idempotentHint 的工具才允许缓存
+func (r *Registry) EnableCache(name string, opts CacheOptions) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tool, ok := r.resolved[name]
+	if !ok {
+		return fmt.Errorf("tool '%s' not found", name)
+	}
+	if annotations := tool.def.Annotations; annotations == nil || annotations.IdempotentHint == nil || !*annotations.IdempotentHint {
+		return fmt.Errorf("tool '%s' is not annotated as idempotent and cannot be cached", name)
+	}
+	if opts.TTL <= 0 {
+		return fmt.Errorf("cache TTL for tool '%s' must be positive", name)
+	}
+	tool.cache = newResultCache(opts)
+	return nil
+}
+
+// DisableCache 关闭工具的结果缓存并丢弃已缓存的结果
+func (r *Registry) DisableCache(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if tool, ok := r.resolved[name]; ok {
+		tool.cache = nil
+	}
+}
+
+// CacheStats 返回所有开启了缓存的工具的缓存统计
+func (r *Registry) CacheStats() map[string]CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make(map[string]CacheStats)
+	for _, tool := range r.entries {
+		if tool.cache != nil {
+			stats[tool.name] = tool.cache.snapshot()
+		}
+	}
+	return stats
+}
+
+// ForgetSession 释放会话结束后不再需要的按会话限流状态
+func (r *Registry) ForgetSession(sessionID string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, tool := range r.entries {
+		if tool.limits != nil {
+			tool.limits.forgetSession(sessionID)
+		}
+	}
+}
+
+func (t *registeredTool) ensureLimits() *toolLimits {
+	if t.limits == nil {
+		t.limits = &toolLimits{sessions: make(map[string]*limiter)}
+	}
+	return t.limits
+}
+
+// Call 通过中间件链执行一个已启用的工具，并对工具本身施加缓存、限流和超时限制。
+// 工具或中间件中的 panic 会被恢复为 *PanicError
+func (r *Registry) Call(ctx context.Context, name string, inputs map[string]any) (result *ExecuteToolResult, err error) {
+	r.mu.RLock()
+	tool, ok := r.resolved[name]
+	if !ok || !tool.enabled {
+		r.mu.RUnlock()
+		return nil, errors.Wrapf(ErrToolNotFound, "unknown tool '%s'", name)
+	}
+	// 别名和目标共享缓存、panic 计数等按名称记录的状态，统一使用解析后的名称
+	name = tool.name
+	fn := tool.fn
+	timeout := tool.timeout
+	if timeout == 0 {
+		timeout = r.defaultTimeout
+	}
+	limits := tool.limits
+	cache := tool.cache
+	middlewares := make([]Middleware, 0, len(r.middlewares)+len(tool.middlewares))
+	middlewares = append(middlewares, r.middlewares...)
+	middlewares = append(middlewares, tool.middlewares...)
+	r.mu.RUnlock()
+
+	var handler Handler = func(ctx context.Context, req *CallRequest) (*ExecuteToolResult, error) {
+		return callWithTimeout(ctx, req.ToolName, fn, req.Inputs, timeout)
+	}
+	if limits != nil {
+		handler = limits.rateLimited(handler)
+	}
+	// 缓存位于限流之外，命中缓存的调用不消耗配额
+	if cache != nil {
+		handler = cache.cached(handler)
+	}
+	handler = Chain(handler, middlewares...)
+
+	defer recoverPanic(name, &result, &err)
+	return handler(ctx, &CallRequest{ToolName: name, Inputs: inputs})
+}
+
+// OnChange 注册一个在工具集合变化时调用的监听者，返回用于取消监听的函数
+func (r *Registry) OnChange(fn func()) (remove func()) {
+	r.mu.Lock()
+	id := r.nextID
+	r.nextID++
+	r.listeners[id] = fn
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		delete(r.listeners, id)
+		r.mu.Unlock()
+	}
+}
+
+// Batch 执行 fn 并把期间的所有变更合并为一次通知，用于一次性应用多项修改 (例如重新加载配置)
+func (r *Registry) Batch(fn func()) {
+	r.mu.Lock()
+	r.batchDepth++
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.batchDepth--
+		pending := r.batchDepth == 0 && r.batchPending
+		if pending {
+			r.batchPending = false
+		}
+		r.mu.Unlock()
+
+		if pending {
+			r.notify()
+		}
+	}()
+	fn()
+}
+
+// notify 在不持有锁的情况下调用所有监听者，批量修改期间只记录有待发送的通知
+func (r *Registry) notify() {
+	r.mu.Lock()
+	if r.batchDepth > 0 {
+		r.batchPending = true
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	r.mu.RLock()
+	listeners := make([]func(), 0, len(r.listeners))
+	for _, fn := range r.listeners {
+		listeners = append(listeners, fn)
+	}
+	r.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn()
+	}
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"context"
+	"testing"
+)
+
+func echoTool(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+	return NewTextResult("ok"), nil
+}
+
+func TestRegistryChangeNotifications(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *Registry)
+		want   int
+	}{
+		{name: "register", change: func(r *Registry) { r.Register(ToolDefinition{Name: "b"}, echoTool) }, want: 1},
+		{name: "replace", change: func(r *Registry) { r.Register(ToolDefinition{Name: "a"}, echoTool) }, want: 1},
+		{name: "unregister", change: func(r *Registry) { r.Unregister("a") }, want: 1},
+		{name: "unregister unknown tool", change: func(r *Registry) { r.Unregister("missing") }, want: 0},
+		{name: "disable", change: func(r *Registry) { r.SetEnabled("a", false) }, want: 1},
+		{name: "enable an enabled tool", change: func(r *Registry) { r.SetEnabled("a", true) }, want: 0},
+		{name: "register and unregister in a batch", change: func(r *Registry) {
+			r.Batch(func() {
+				r.Register(ToolDefinition{Name: "b"}, echoTool)
+				r.Register(ToolDefinition{Name: "c"}, echoTool)
+				r.Unregister("a")
+			})
+		}, want: 1},
+		{name: "nested batches", change: func(r *Registry) {
+			r.Batch(func() {
+				r.Register(ToolDefinition{Name: "b"}, echoTool)
+				r.Batch(func() { r.Unregister("b") })
+			})
+		}, want: 1},
+		{name: "batch without changes", change: func(r *Registry) { r.Batch(func() {}) }, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.Register(ToolDefinition{Name: "a"}, echoTool)
+
+			notifications := 0
+			remove := r.OnChange(func() { notifications++ })
+			tt.change(r)
+			if notifications != tt.want {
+				t.Errorf("got %d notifications, want %d", notifications, tt.want)
+			}
+
+			remove()
+			r.Register(ToolDefinition{Name: "d"}, echoTool)
+			if notifications != tt.want {
+				t.Error("listener was called after it was removed")
+			}
+		})
+	}
+}
+
+func TestToolsMap(t *testing.T) {
+	toolsMap := NewToolsMap()
+	for _, def := range NewDefaultRegistry().List() {
+		if got, ok := toolsMap.GetTool(def.Name); !ok || got.Name != def.Name {
+			t.Errorf("GetTool(%q) = %+v, %v", def.Name, got, ok)
+		}
+	}
+
+	toolsMap.AddTool(ToolDefinition{Name: "extra"})
+	if _, ok := toolsMap.GetTool("extra"); !ok {
+		t.Error("AddTool did not add the tool")
+	}
+	if _, ok := toolsMap.GetTool("missing"); ok {
+		t.Error("GetTool found a tool that was never added")
+	}
+}
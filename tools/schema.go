@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateAgainstSchema 校验 value 是否符合给定的对象 schema，
+// 只检查必填字段和各属性的基本类型
+func ValidateAgainstSchema(schema ToolParameters, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal value for schema validation")
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil || obj == nil {
+		return errors.New("value is not a JSON object")
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("missing required property '%s'", name)
+		}
+	}
+
+	for name, prop := range schema.Properties {
+		v, ok := obj[name]
+		if !ok || prop.Type == "" {
+			continue
+		}
+		if !matchesType(prop.Type, v) {
+			return fmt.Errorf("property '%s' should be of type %s, got %T", name, prop.Type, v)
+		}
+	}
+	return nil
+}
+
+// matchesType 判断解码后的 JSON 值是否匹配 JSON Schema 的基本类型
+func matchesType(typ string, v any) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "null":
+		return v == nil
+	}
+	// 未知类型不做限制
+	return true
+}
@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultToolTimeout 是未单独配置超时的工具的默认执行时限
+const DefaultToolTimeout = 30 * time.Second
+
+// ErrToolTimeout 表示工具执行超过了时限
+var ErrToolTimeout = errors.New("tool execution timed out")
+
+// callWithTimeout 在独立的 goroutine 中执行工具，超过 timeout 时取消工具的 ctx 并立即返回超时错误。
+// timeout 为 0 表示不限制。超时返回后工具仍占用并发名额，直到 goroutine 真正结束
+func callWithTimeout(ctx context.Context, name string, fn ToolFunc, inputs map[string]any, timeout time.Duration) (*ExecuteToolResult, error) {
+	if timeout <= 0 {
+		return fn(ctx, inputs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		result *ExecuteToolResult
+		err    error
+	}
+	done := make(chan outcome, 1) // 带缓冲，超时后工具返回时不会阻塞
+
+	release := holdCallSlots(ctx)
+	go func() {
+		var o outcome
+		defer release()
+		defer func() { done <- o }()
+		// 在 goroutine 内恢复 panic，否则会直接导致进程崩溃
+		defer recoverPanic(name, &o.result, &o.err)
+		o.result, o.err = fn(ctx, inputs)
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errors.Wrapf(ErrToolTimeout, "tool '%s' did not finish within %s", name, timeout)
+		}
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestCallWithTimeout(t *testing.T) {
+	blocking := func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	quick := func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		return NewTextResult("ok"), nil
+	}
+	panicking := func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		panic("boom")
+	}
+
+	tests := []struct {
+		name    string
+		fn      ToolFunc
+		timeout time.Duration
+		cancel  bool // 调用前取消 ctx
+		wantErr error
+	}{
+		{name: "finishes in time", fn: quick, timeout: time.Second},
+		{name: "no timeout", fn: quick},
+		{name: "timeout", fn: blocking, timeout: 10 * time.Millisecond, wantErr: ErrToolTimeout},
+		{name: "cancelled by the caller", fn: blocking, timeout: time.Minute, cancel: true, wantErr: context.Canceled},
+		{name: "panic in the tool goroutine", fn: panicking, timeout: time.Second, wantErr: &PanicError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			result, err := callWithTimeout(ctx, "tool", tt.fn, nil, tt.timeout)
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil || result == nil {
+					t.Fatalf("got (%v, %v), want a result", result, err)
+				}
+			case *PanicError:
+				var panicErr *PanicError
+				if !errors.As(err, &panicErr) {
+					t.Fatalf("err = %v, want a *PanicError", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("err = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCallWithTimeoutCancelsTool(t *testing.T) {
+	cancelled := make(chan struct{})
+	fn := func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+
+	if _, err := callWithTimeout(context.Background(), "tool", fn, nil, 10*time.Millisecond); !errors.Is(err, ErrToolTimeout) {
+		t.Fatalf("err = %v, want ErrToolTimeout", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the tool's context was not cancelled after the timeout")
+	}
+}
+
+func TestTimedOutToolKeepsConcurrencySlot(t *testing.T) {
+	unblock := make(chan struct{})
+	finished := make(chan struct{}, 10)
+	r := NewRegistry()
+	r.Register(ToolDefinition{Name: "stuck"}, func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		defer func() { finished <- struct{}{} }()
+		<-unblock // 忽略 ctx，模拟不响应取消的工具
+		return NewTextResult("ok"), nil
+	})
+	if err := r.SetTimeout("stuck", 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetRateLimit("stuck", RateLimit{MaxConcurrent: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Call(context.Background(), "stuck", nil); !errors.Is(err, ErrToolTimeout) {
+		t.Fatalf("first call: err = %v, want ErrToolTimeout", err)
+	}
+	var rateLimitErr *RateLimitError
+	if _, err := r.Call(context.Background(), "stuck", nil); !errors.As(err, &rateLimitErr) {
+		t.Fatalf("second call: err = %v, want a *RateLimitError while the first call is still running", err)
+	}
+
+	close(unblock)
+	<-finished
+	// 名额在 goroutine 的 defer 中归还，稍等片刻
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := r.Call(context.Background(), "stuck", nil)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("slot was not released after the tool finished: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -1,17 +1,63 @@
 package tools
 
-var ToolFuncMap = map[string]func(inputs map[string]any) (*ExecuteToolResult, error){
-	"get_weather": func(inputs map[string]any) (*ExecuteToolResult, error) {
-		return GetWeather(inputs["location"].(string))
+import (
+	"context"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// ToolFunc 是工具的执行函数，工具应在 ctx 被取消时尽快返回
+type ToolFunc func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error)
+
+var ToolFuncMap = map[string]ToolFunc{
+	"get_weather": func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		location, err := stringArg(inputs, "location")
+		if err != nil {
+			return nil, err
+		}
+		return GetWeather(ctx, location)
 	},
-	"caculator": func(inputs map[string]any) (*ExecuteToolResult, error) {
-		return ExecuteCaculate(inputs["operation"].(string), inputs["num1"].(float64), inputs["num2"].(float64))
+	"caculator": func(ctx context.Context, inputs map[string]any) (*ExecuteToolResult, error) {
+		operation, err := stringArg(inputs, "operation")
+		if err != nil {
+			return nil, err
+		}
+		num1, err := numberArg(inputs, "num1")
+		if err != nil {
+			return nil, err
+		}
+		num2, err := numberArg(inputs, "num2")
+		if err != nil {
+			return nil, err
+		}
+		return ExecuteCaculate(operation, num1, num2)
 	},
 }
 
+// stringArg 读取字符串参数。服务器在调用前已按 inputSchema 校验参数，
+// 这里的检查用于不经过校验的调用方 (例如 tools call 子命令)，避免类型断言 panic
+func stringArg(inputs map[string]any, name string) (string, error) {
+	v, ok := inputs[name].(string)
+	if !ok {
+		return "", errors.Errorf("argument '%s' must be a string", name)
+	}
+	return v, nil
+}
+
+// numberArg 读取数字参数，见 stringArg
+func numberArg(inputs map[string]any, name string) (float64, error) {
+	v, ok := inputs[name].(float64)
+	if !ok {
+		return 0, errors.Errorf("argument '%s' must be a number", name)
+	}
+	return v, nil
+}
+
 var supportTools = []ToolDefinition{
 	{
 		Name:        "get_weather",
+		Title:       "Current Weather",
 		Description: "Fetches the current weather for a given location.",
 		InputSchema: ToolParameters{
 			Type: "object",
@@ -20,9 +66,26 @@ var supportTools = []ToolDefinition{
 			},
 			Required: []string{"location"},
 		},
+		OutputSchema: &ToolParameters{
+			Type: "object",
+			Properties: map[string]ToolParameterProperties{
+				"location": {Type: "string", Description: "The resolved location name."},
+				"temp_c":   {Type: "number", Description: "The current temperature in Celsius."},
+				"weather":  {Type: "string", Description: "The current weather condition."},
+			},
+			Required: []string{"location", "temp_c", "weather"},
+		},
+		// 只读取外部天气服务，不修改任何状态
+		Annotations: &ToolAnnotations{
+			ReadOnlyHint:    boolPtr(true),
+			DestructiveHint: boolPtr(false),
+			IdempotentHint:  boolPtr(true),
+			OpenWorldHint:   boolPtr(true),
+		},
 	},
 	{
 		Name:        "caculator",
+		Title:       "Calculator",
 		Description: "A simple calculator tool that can add, subtract, multiply, and divide.",
 		InputSchema: ToolParameters{
 			Type: "object",
@@ -33,6 +96,13 @@ var supportTools = []ToolDefinition{
 			},
 			Required: []string{"operation", "num1", "num2"},
 		},
+		// 纯计算，不与外部世界交互
+		Annotations: &ToolAnnotations{
+			ReadOnlyHint:    boolPtr(true),
+			DestructiveHint: boolPtr(false),
+			IdempotentHint:  boolPtr(true),
+			OpenWorldHint:   boolPtr(false),
+		},
 	},
 }
 
@@ -49,39 +119,88 @@ type ToolParameters struct {
 	Required   []string                           `json:"required,omitempty"`
 }
 
-// ToolDefinition 定义了一个工具
-type ToolDefinition struct {
-	Name        string         `json:"name"`
-	Description string         `json:"description,omitempty"`
-	InputSchema ToolParameters `json:"inputSchema"`
-}
-
-// ExecuteToolParams 是 tool/execute 请求的参数
-type ExecuteToolParams struct {
-	ToolName string         `json:"name"`
-	Inputs   map[string]any `json:"arguments"`
+// ToolAnnotations 描述工具行为的提示，宿主据此决定是否需要用户确认。
+// 这些只是提示，客户端不应把它们当作安全保证
+type ToolAnnotations struct {
+	ReadOnlyHint    *bool `json:"readOnlyHint,omitempty"`    // 工具不会修改其环境
+	DestructiveHint *bool `json:"destructiveHint,omitempty"` // 工具可能执行破坏性的更新 (仅在非只读时有意义)
+	IdempotentHint  *bool `json:"idempotentHint,omitempty"`  // 使用相同参数重复调用不会产生额外影响
+	OpenWorldHint   *bool `json:"openWorldHint,omitempty"`   // 工具会与外部实体交互
 }
 
-// ExecuteToolResult 是 tool/execute 请求成功时的结果
-type ExecuteToolResult struct {
-	Content []map[string]any `json:"content"`
+// ToolDefinition 定义了一个工具
+type ToolDefinition struct {
+	Name         string           `json:"name"`
+	Title        string           `json:"title,omitempty"` // 供界面展示的名称
+	Description  string           `json:"description,omitempty"`
+	InputSchema  ToolParameters   `json:"inputSchema"`
+	OutputSchema *ToolParameters  `json:"outputSchema,omitempty"` // 可选，声明 structuredContent 的结构
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
 }
 
+// ToolsMap 是按名称索引的工具定义
+//
+// Deprecated: 使用 Registry，它同时管理工具的执行函数、启用状态和来源
 type ToolsMap map[string]ToolDefinition
 
+// NewToolsMap 返回内置工具的定义
+//
+// Deprecated: 使用 NewDefaultRegistry().List()
 func NewToolsMap() ToolsMap {
 	toolsMap := make(ToolsMap)
-	for _, tool := range supportTools {
+	for _, tool := range NewDefaultRegistry().List() {
 		toolsMap.AddTool(tool)
 	}
 	return toolsMap
 }
 
+// Deprecated: 使用 Registry.Register
 func (t ToolsMap) AddTool(tool ToolDefinition) {
 	t[tool.Name] = tool
 }
 
+// Deprecated: 使用 Registry.GetTool
 func (t ToolsMap) GetTool(name string) (ToolDefinition, bool) {
 	tool, ok := t[name]
 	return tool, ok
 }
+
+// ExecuteToolParams 是 tool/execute 请求的参数
+type ExecuteToolParams struct {
+	ToolName string         `json:"name"`
+	Inputs   map[string]any `json:"arguments"`
+}
+
+// ExecuteToolResult 是 tool/execute 请求成功时的结果
+type ExecuteToolResult struct {
+	Content           []Content      `json:"content"`
+	StructuredContent any            `json:"structuredContent,omitempty"` // 符合 outputSchema 的结构化输出
+	IsError           bool           `json:"isError,omitempty"`           // 工具执行失败时为 true，让模型能看到错误并做出反应
+	Meta              map[string]any `json:"_meta,omitempty"`             // 附加信息，例如限流时的 retryAfterSeconds
+}
+
+// NewErrorResult 把工具执行错误包装成 isError 结果
+func NewErrorResult(err error) *ExecuteToolResult {
+	return &ExecuteToolResult{
+		Content: []Content{NewTextContent(err.Error())},
+		IsError: true,
+	}
+}
+
+// NewRateLimitResult 把限流错误包装成 isError 结果，并在 _meta 中附带建议的重试间隔 (向上取整的秒数)
+func NewRateLimitResult(err *RateLimitError) *ExecuteToolResult {
+	result := NewErrorResult(err)
+	result.Meta = map[string]any{"retryAfterSeconds": math.Ceil(err.RetryAfter.Seconds())}
+	return result
+}
+
+// NewTextResult 构造只包含一个文本内容块的结果
+func NewTextResult(text string) *ExecuteToolResult {
+	return &ExecuteToolResult{
+		Content: []Content{NewTextContent(text)},
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
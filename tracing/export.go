@@ -0,0 +1,328 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Exporter 把一批已结束的 span 发送到目的地
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Close() error
+}
+
+// 导出队列的参数
+const (
+	queueSize      = 2048
+	maxBatchSize   = 256
+	exportInterval = 2 * time.Second
+	exportTimeout  = 10 * time.Second
+)
+
+// Provider 收集结束的 span，在后台按批交给 Exporter
+type Provider struct {
+	exporter Exporter
+	logger   *slog.Logger
+
+	queue     chan SpanData
+	flush     chan chan struct{}
+	done      chan struct{} // 关闭后 run 导出剩余的 span 并退出
+	stopped   chan struct{} // run 退出时关闭
+	closeOnce sync.Once
+}
+
+// NewProvider 创建 Provider 并启动后台导出，使用完毕后需要调用 Shutdown
+func NewProvider(exporter Exporter, logger *slog.Logger) *Provider {
+	p := &Provider{
+		exporter: exporter,
+		logger:   logger,
+		queue:    make(chan SpanData, queueSize),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// enqueue 把 span 放入导出队列，队列已满时丢弃
+func (p *Provider) enqueue(span SpanData) {
+	select {
+	case p.queue <- span:
+	default:
+		p.logger.Warn("Trace export queue is full, dropping span", "span", span.Name)
+	}
+}
+
+func (p *Provider) run() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []SpanData
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		if err := p.exporter.Export(ctx, batch); err != nil {
+			p.logger.Warn("Failed to export spans", "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+	drain := func() {
+		for {
+			select {
+			case span := <-p.queue:
+				batch = append(batch, span)
+				if len(batch) >= maxBatchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= maxBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-p.flush:
+			drain()
+			close(ack)
+		case <-p.done:
+			drain()
+			return
+		}
+	}
+}
+
+// Flush 立即导出队列中的所有 span
+func (p *Provider) Flush() {
+	ack := make(chan struct{})
+	select {
+	case p.flush <- ack:
+		<-ack
+	case <-p.done:
+	}
+}
+
+// Shutdown 导出剩余的 span 并关闭 Exporter
+func (p *Provider) Shutdown() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.done)
+		// 等待 run 导出剩余的 span，之后才能关闭 Exporter
+		<-p.stopped
+		err = p.exporter.Close()
+	})
+	return err
+}
+
+// FileExporter 把每批 span 作为一行 OTLP JSON (ExportTraceServiceRequest) 追加写入文件，
+// 与 OpenTelemetry Collector 的 file exporter 输出格式一致
+type FileExporter struct {
+	serviceName string
+
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewFileExporter 以追加方式打开 path。span 属性中可能带有会话和调用参数的信息，新建的文件只有所有者可读写
+func NewFileExporter(path, serviceName string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open trace file")
+	}
+	return &FileExporter{serviceName: serviceName, w: file, closer: file}, nil
+}
+
+func (e *FileExporter) Export(ctx context.Context, spans []SpanData) error {
+	line, err := json.Marshal(encodeOTLP(e.serviceName, spans))
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal spans")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(line, '\n'))
+	return err
+}
+
+func (e *FileExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// OTLPExporter 以 OTLP/HTTP JSON 编码把 span 发送到 collector
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+}
+
+// NewOTLPExporter 创建 OTLP/HTTP 导出器。endpoint 是 collector 的基础地址 (例如 http://127.0.0.1:4318)，
+// 不以 /v1/traces 结尾时会自动补上
+func NewOTLPExporter(endpoint, serviceName string, headers map[string]string) *OTLPExporter {
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &OTLPExporter{
+		url:         url,
+		serviceName: serviceName,
+		headers:     headers,
+		client:      &http.Client{Timeout: exportTimeout},
+	}
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(encodeOTLP(e.serviceName, spans))
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal spans")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create OTLP request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send spans")
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("OTLP endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// 以下类型对应 OTLP JSON 编码中用到的部分，ID 使用十六进制字符串，64 位整数使用十进制字符串
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// scopeName 是导出的 span 的 instrumentation scope 名称
+const scopeName = "github.com/n8sPxD/mcp-server-demo"
+
+func encodeOTLP(serviceName string, spans []SpanData) otlpRequest {
+	encoded := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		encoded[i] = otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			TraceState:        span.TraceState,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			encoded[i].ParentSpanID = span.ParentSpanID.String()
+		}
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes([]Attribute{Attr("service.name", serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: encoded}},
+	}}}
+}
+
+func encodeAttributes(attrs []Attribute) []otlpKeyValue {
+	encoded := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value map[string]any
+		switch v := attr.Value.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return encoded
+}
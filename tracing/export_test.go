@@ -0,0 +1,253 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryExporter 在内存中保存导出的 span
+type memoryExporter struct {
+	mu     sync.Mutex
+	spans  []SpanData
+	closed bool
+	late   bool // Close 之后仍有 Export 调用
+	delay  time.Duration
+}
+
+func (e *memoryExporter) Export(ctx context.Context, spans []SpanData) error {
+	time.Sleep(e.delay)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		e.late = true
+	}
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memoryExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	return nil
+}
+
+func (e *memoryExporter) exported() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestProviderShutdown(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans int
+		delay time.Duration
+	}{
+		{name: "no spans", spans: 0},
+		{name: "single batch", spans: 3},
+		{name: "several batches", spans: maxBatchSize*2 + 1},
+		{name: "slow exporter", spans: 5, delay: 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &memoryExporter{delay: tt.delay}
+			provider := NewProvider(exporter, discardLogger())
+			for i := 0; i < tt.spans; i++ {
+				provider.enqueue(SpanData{Name: "span"})
+			}
+			if err := provider.Shutdown(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(exporter.exported()); got != tt.spans {
+				t.Errorf("exported %d spans, want %d", got, tt.spans)
+			}
+			if !exporter.closed || exporter.late {
+				t.Errorf("exporter closed = %v, exported after close = %v", exporter.closed, exporter.late)
+			}
+			// 重复调用是安全的
+			if err := provider.Shutdown(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	traceID := TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	parent := SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+	start := time.Unix(1700000000, 500)
+	spans := []SpanData{
+		{
+			Name:         "tools/call",
+			Kind:         KindServer,
+			TraceID:      traceID,
+			SpanID:       SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+			ParentSpanID: parent,
+			TraceState:   "vendor=1",
+			Start:        start,
+			End:          start.Add(time.Millisecond),
+			Attributes: []Attribute{
+				Attr("rpc.method", "tools/call"),
+				Attr("cache.hit", false),
+				Attr("http.status_code", 200),
+				Attr("retry.after", 1.5),
+			},
+			Status:        StatusError,
+			StatusMessage: "boom",
+		},
+		{Name: "root", Kind: KindInternal, TraceID: traceID, SpanID: SpanID{8, 7, 6, 5, 4, 3, 2, 1}, Start: start, End: start},
+	}
+
+	tests := []struct {
+		name     string
+		endpoint func(url string) string
+		status   int
+		wantErr  bool
+	}{
+		{name: "base endpoint", endpoint: func(url string) string { return url }, status: http.StatusOK},
+		{name: "full endpoint", endpoint: func(url string) string { return url + "/v1/traces/" }, status: http.StatusOK},
+		{name: "collector error", endpoint: func(url string) string { return url }, status: http.StatusServiceUnavailable, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				gotPath    string
+				gotHeaders http.Header
+				gotBody    map[string]any
+			)
+			collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath, gotHeaders = r.URL.Path, r.Header
+				if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+					t.Errorf("collector received invalid JSON: %v", err)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer collector.Close()
+
+			exporter := NewOTLPExporter(tt.endpoint(collector.URL), "mcp-test", map[string]string{"Authorization": "Bearer token"})
+			defer exporter.Close()
+			err := exporter.Export(context.Background(), spans)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Export() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if gotPath != "/v1/traces" {
+				t.Errorf("path = %q, want /v1/traces", gotPath)
+			}
+			if got := gotHeaders.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if got := gotHeaders.Get("Authorization"); got != "Bearer token" {
+				t.Errorf("Authorization = %q", got)
+			}
+			resource := gotBody["resourceSpans"].([]any)[0].(map[string]any)
+			service := resource["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+			if service["key"] != "service.name" || service["value"].(map[string]any)["stringValue"] != "mcp-test" {
+				t.Errorf("resource attribute = %v", service)
+			}
+			if got := len(resource["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)); got != len(spans) {
+				t.Errorf("collector received %d spans, want %d", got, len(spans))
+			}
+		})
+	}
+}
+
+func TestEncodeOTLP(t *testing.T) {
+	start := time.Unix(1700000000, 500)
+	span := SpanData{
+		Name:         "get_weather",
+		Kind:         KindClient,
+		TraceID:      TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:       SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		ParentSpanID: SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		Start:        start,
+		End:          start.Add(time.Second),
+		Attributes: []Attribute{
+			Attr("s", "v"),
+			Attr("b", true),
+			Attr("i", 7),
+			Attr("i64", int64(1)<<40),
+			Attr("f", 0.25),
+			Attr("other", time.Second),
+		},
+		Status:        StatusError,
+		StatusMessage: "failed",
+	}
+	encoded := encodeOTLP("svc", []SpanData{span, {SpanID: SpanID{9}}})
+	spans := encoded.ResourceSpans[0].ScopeSpans[0].Spans
+	got := spans[0]
+
+	checks := []struct {
+		field, got, want string
+	}{
+		{"traceId", got.TraceID, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"spanId", got.SpanID, "0102030405060708"},
+		{"parentSpanId", got.ParentSpanID, "00f067aa0ba902b7"},
+		{"startTimeUnixNano", got.StartTimeUnixNano, "1700000000000000500"},
+		{"endTimeUnixNano", got.EndTimeUnixNano, "1700000001000000500"},
+		{"root parentSpanId", spans[1].ParentSpanID, ""},
+		{"scope", encoded.ResourceSpans[0].ScopeSpans[0].Scope.Name, scopeName},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.field, c.got, c.want)
+		}
+	}
+	if got.Kind != KindClient || got.Status.Code != StatusError || got.Status.Message != "failed" {
+		t.Errorf("kind = %v, status = %+v", got.Kind, got.Status)
+	}
+
+	wantAttrs := []struct {
+		key   string
+		value map[string]any
+	}{
+		{"s", map[string]any{"stringValue": "v"}},
+		{"b", map[string]any{"boolValue": true}},
+		{"i", map[string]any{"intValue": "7"}},
+		{"i64", map[string]any{"intValue": "1099511627776"}},
+		{"f", map[string]any{"doubleValue": 0.25}},
+		{"other", map[string]any{"stringValue": "1s"}},
+	}
+	if len(got.Attributes) != len(wantAttrs) {
+		t.Fatalf("got %d attributes, want %d", len(got.Attributes), len(wantAttrs))
+	}
+	for i, want := range wantAttrs {
+		attr := got.Attributes[i]
+		if attr.Key != want.key || !reflect.DeepEqual(attr.Value, want.value) {
+			t.Errorf("attribute %d = %s: %v, want %s: %v", i, attr.Key, attr.Value, want.key, want.value)
+		}
+	}
+}
+
+func TestFileExporterPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := NewFileExporter(path, "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("trace file mode = %o, want 600", perm)
+	}
+}
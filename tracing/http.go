@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"net/http"
+)
+
+// Transport 为每个出站 HTTP 请求创建一个 client span，并通过 traceparent 头把调用链传给上游。
+// span 中只记录方法、主机和路径，不记录查询参数，其中可能含有 API key
+type Transport struct {
+	Base http.RoundTripper // 为 nil 时使用 http.DefaultTransport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := Start(req.Context(), req.Method, KindClient,
+		Attr("http.request.method", req.Method),
+		Attr("server.address", req.URL.Hostname()),
+		Attr("url.scheme", req.URL.Scheme),
+		Attr("url.path", req.URL.Path),
+	)
+	if span == nil {
+		return base.RoundTrip(req)
+	}
+	defer span.End()
+
+	// RoundTripper 不能修改传入的请求
+	req = req.Clone(ctx)
+	req.Header.Set("traceparent", span.SpanContext().Traceparent())
+	if state := span.SpanContext().TraceState; state != "" {
+		req.Header.Set("tracestate", state)
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.SetStatus(StatusError, "request failed")
+		return nil, err
+	}
+	span.SetAttributes(Attr("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(StatusError, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
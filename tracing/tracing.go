@@ -0,0 +1,306 @@
+// Package tracing 实现与 OpenTelemetry 兼容的最小链路追踪：W3C traceparent 传播、
+// 带父子关系的 span，以及以 OTLP JSON 格式导出到本地文件或 OTLP/HTTP 端点
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID 标识一条调用链
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID 标识调用链中的一个 span
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		for i := range id {
+			id[i] = byte(rand.Uint32())
+		}
+	}
+	return id
+}
+
+// SpanContext 是在进程间传播的 span 标识
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string // 原样传递的 tracestate
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent 返回 W3C traceparent 头的值
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent 解析 W3C traceparent 头，格式不合法时返回 false
+func ParseTraceparent(traceparent, tracestate string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, false
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	sc.TraceState = tracestate
+	return sc, true
+}
+
+// SpanKind 与 OTLP 中 span kind 的取值一致
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// StatusCode 与 OTLP 中 status code 的取值一致
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute 是 span 的属性，Value 应为 string、bool、整数或浮点数
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Attr 创建一个属性
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData 是已结束的 span，交给 Exporter 导出
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID // 根 span 为零值
+	TraceState    string
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span 是正在进行的一次操作。nil 的 *Span 上的所有方法都不做任何事，
+// 没有配置导出时 Start 返回 nil，调用方无需判断
+type Span struct {
+	provider *Provider
+	sc       SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext 返回 span 的标识
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes 添加属性，同名属性以后添加的为准
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// SetStatus 设置 span 的状态，message 只在 StatusError 时有意义
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = code
+	if code == StatusError {
+		s.data.StatusMessage = message
+	} else {
+		s.data.StatusMessage = ""
+	}
+}
+
+// RecordError 把 span 标记为失败，err 为 nil 时不做任何事
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End 结束 span 并交给导出队列，重复调用只有第一次有效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Attributes = dedupAttributes(data.Attributes)
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		s.provider.enqueue(data)
+	}
+}
+
+// dedupAttributes 去掉重复的属性，保留最后一次设置的值
+func dedupAttributes(attrs []Attribute) []Attribute {
+	index := make(map[string]int, len(attrs))
+	out := make([]Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		if i, ok := index[attr.Key]; ok {
+			out[i] = attr
+			continue
+		}
+		index[attr.Key] = len(out)
+		out = append(out, attr)
+	}
+	return out
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// SpanFromContext 返回 ctx 中当前的 span，没有时返回 nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent 把从请求中提取的远端 span 标识放入 ctx，之后 Start 创建的 span 以它为父 span
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// parentFromContext 返回 ctx 中的父 span 标识，优先使用本进程的 span
+func parentFromContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.sc, true
+	}
+	sc, ok := ctx.Value(remoteKey{}).(SpanContext)
+	return sc, ok
+}
+
+var global atomic.Pointer[Provider]
+
+// SetProvider 设置 Start 使用的 Provider，传入 nil 关闭追踪
+func SetProvider(p *Provider) {
+	global.Store(p)
+}
+
+// Start 创建一个 span，ctx 中有 span 或远端父 span 时作为其子 span。
+// 没有设置 Provider 时返回原 ctx 和 nil
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	p := global.Load()
+	if p == nil {
+		return ctx, nil
+	}
+
+	sc := SpanContext{SpanID: newSpanID(), Sampled: true}
+	var parentID SpanID
+	if parent, ok := parentFromContext(ctx); ok {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+		sc.TraceState = parent.TraceState
+		parentID = parent.SpanID
+	} else {
+		sc.TraceID = newTraceID()
+	}
+
+	span := &Span{
+		provider: p,
+		sc:       sc,
+		data: SpanData{
+			Name:         name,
+			Kind:         kind,
+			TraceID:      sc.TraceID,
+			SpanID:       sc.SpanID,
+			ParentSpanID: parentID,
+			TraceState:   sc.TraceState,
+			Start:        time.Now(),
+			Attributes:   append([]Attribute(nil), attrs...),
+		},
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	tests := []struct {
+		name        string
+		traceparent string
+		ok          bool
+		sampled     bool
+	}{
+		{name: "sampled", traceparent: "00-" + traceID + "-" + spanID + "-01", ok: true, sampled: true},
+		{name: "not sampled", traceparent: "00-" + traceID + "-" + spanID + "-00", ok: true},
+		{name: "surrounding space", traceparent: "  00-" + traceID + "-" + spanID + "-01 ", ok: true, sampled: true},
+		{name: "future version with extra fields", traceparent: "01-" + traceID + "-" + spanID + "-01-extra", ok: true, sampled: true},
+		{name: "version 00 with extra fields", traceparent: "00-" + traceID + "-" + spanID + "-01-extra"},
+		{name: "forbidden version", traceparent: "ff-" + traceID + "-" + spanID + "-01"},
+		{name: "zero trace id", traceparent: "00-00000000000000000000000000000000-" + spanID + "-01"},
+		{name: "zero span id", traceparent: "00-" + traceID + "-0000000000000000-01"},
+		{name: "short trace id", traceparent: "00-4bf92f35-" + spanID + "-01"},
+		{name: "non-hex", traceparent: "00-" + traceID + "-zzf067aa0ba902b7-01"},
+		{name: "empty", traceparent: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.traceparent, "vendor=1")
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID {
+				t.Errorf("got trace %s span %s", sc.TraceID, sc.SpanID)
+			}
+			if sc.Sampled != tt.sampled {
+				t.Errorf("sampled = %v, want %v", sc.Sampled, tt.sampled)
+			}
+			if sc.TraceState != "vendor=1" {
+				t.Errorf("tracestate = %q", sc.TraceState)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: sampled}
+		parsed, ok := ParseTraceparent(sc.Traceparent(), "")
+		if !ok || parsed != sc {
+			t.Errorf("round trip of %s = %+v, %v", sc.Traceparent(), parsed, ok)
+		}
+	}
+}
+
+func TestStartWithRemoteParent(t *testing.T) {
+	parent, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")
+	if !ok {
+		t.Fatal("invalid parent")
+	}
+
+	exporter := &memoryExporter{}
+	provider := NewProvider(exporter, discardLogger())
+	SetProvider(provider)
+	defer SetProvider(nil)
+
+	ctx, span := Start(ContextWithRemoteParent(context.Background(), parent), "server", KindServer)
+	_, child := Start(ctx, "child", KindInternal)
+	child.End()
+	span.End()
+	if err := provider.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.exported()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	childData, serverData := spans[0], spans[1]
+	if serverData.TraceID != parent.TraceID || serverData.ParentSpanID != parent.SpanID {
+		t.Errorf("server span is not a child of the remote parent: %+v", serverData)
+	}
+	if childData.TraceID != parent.TraceID || childData.ParentSpanID != serverData.SpanID {
+		t.Errorf("child span is not a child of the server span: %+v", childData)
+	}
+}